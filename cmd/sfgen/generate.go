@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/catalystcommunity/salesforce-utils/pkg"
+)
+
+// typesImport is the import path of the salesforce value types
+const typesImport = "github.com/catalystcommunity/salesforce-utils/pkg/types"
+
+// generate renders the go source for the describes. every field is tagged
+// omitempty so unset fields are left out of create and update bodies.
+func generate(packageName string, describes []pkg.SObjectDescribe) ([]byte, error) {
+	structNames := map[string]string{}
+	usedNames := map[string]bool{"RelatedRecords": true}
+	for _, describe := range describes {
+		structNames[describe.Name] = uniqueName(goName(describe.Name), usedNames)
+	}
+
+	g := &generator{structNames: structNames, usedNames: usedNames, imports: map[string]bool{}}
+	for _, describe := range describes {
+		g.writeObject(describe)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by sfgen. DO NOT EDIT.\n\npackage %s\n\n", packageName)
+	if len(g.imports) > 0 {
+		out.WriteString("import (\n")
+		wroteStandard := false
+		for _, path := range sortedKeys(g.imports) {
+			isStandard := !strings.Contains(strings.Split(path, "/")[0], ".")
+			if wroteStandard && !isStandard {
+				out.WriteString("\n")
+				wroteStandard = false
+			}
+			wroteStandard = wroteStandard || isStandard
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		out.WriteString(")\n\n")
+	}
+	if g.usesRelatedRecords {
+		out.WriteString("// RelatedRecords is the nested query result of a child relationship\n")
+		out.WriteString("type RelatedRecords[T any] struct {\n")
+		out.WriteString("\tTotalSize int `json:\"totalSize\"`\n")
+		out.WriteString("\tDone bool `json:\"done\"`\n")
+		out.WriteString("\tRecords []T `json:\"records\"`\n")
+		out.WriteString("}\n\n")
+	}
+	out.Write(g.body.Bytes())
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated source: %w", err)
+	}
+	return source, nil
+}
+
+type generator struct {
+	// structNames maps the api name of every generated object to its struct
+	// name, used to type relationship fields
+	structNames map[string]string
+	// usedNames are the package level identifiers generated so far
+	usedNames          map[string]bool
+	imports            map[string]bool
+	usesRelatedRecords bool
+	body               bytes.Buffer
+}
+
+func (g *generator) writeObject(describe pkg.SObjectDescribe) {
+	structName := g.structNames[describe.Name]
+	usedFieldNames := map[string]bool{}
+	var constants bytes.Buffer
+
+	objectTypeName := uniqueName(structName+"ObjectType", g.usedNames)
+	fmt.Fprintf(&g.body, "// %s is the api name of %s\n", objectTypeName, structName)
+	fmt.Fprintf(&g.body, "const %s = %q\n\n", objectTypeName, describe.Name)
+	fmt.Fprintf(&g.body, "// %s is generated from the describe of %s\n", structName, describe.Name)
+	fmt.Fprintf(&g.body, "type %s struct {\n", structName)
+	for _, field := range describe.Fields {
+		fieldName := uniqueName(goName(field.Name), usedFieldNames)
+		goType := g.goType(field)
+		comment := fieldComment(field)
+		if comment != "" {
+			fmt.Fprintf(&g.body, "\t// %s\n", comment)
+		}
+		fmt.Fprintf(&g.body, "\t%s %s `json:\"%s,omitempty\"`\n", fieldName, goType, field.Name)
+
+		if field.Type == "reference" && field.RelationshipName != "" {
+			relationshipType := "json.RawMessage"
+			if len(field.ReferenceTo) == 1 && g.structNames[field.ReferenceTo[0]] != "" {
+				relationshipType = "*" + g.structNames[field.ReferenceTo[0]]
+			} else {
+				g.imports["encoding/json"] = true
+			}
+			relationshipName := uniqueName(goName(field.RelationshipName), usedFieldNames)
+			fmt.Fprintf(&g.body, "\t%s %s `json:\"%s,omitempty\"`\n", relationshipName, relationshipType, field.RelationshipName)
+		}
+		if len(field.PicklistValues) > 0 {
+			writePicklistConstants(&constants, structName+fieldName, describe.Name, field, g.usedNames)
+		}
+	}
+	for _, relationship := range describe.ChildRelationships {
+		childStruct := g.structNames[relationship.ChildSObject]
+		if relationship.RelationshipName == "" || childStruct == "" {
+			continue
+		}
+		g.usesRelatedRecords = true
+		relationshipName := uniqueName(goName(relationship.RelationshipName), usedFieldNames)
+		fmt.Fprintf(&g.body, "\t%s *RelatedRecords[%s] `json:\"%s,omitempty\"`\n", relationshipName, childStruct, relationship.RelationshipName)
+	}
+	g.body.WriteString("}\n\n")
+	g.body.Write(constants.Bytes())
+}
+
+// goType maps a salesforce field type to a go type. nillable fields are
+// wrapped in types.Nullable so they can be cleared with an explicit null,
+// other fields are pointers, or nil slices for multipicklists, so they can be
+// left unset. types it doesn't know map to interface{}, so any json value
+// still unmarshals.
+func (g *generator) goType(field pkg.DescribeField) string {
+	switch field.Type {
+	case "address":
+		g.imports[typesImport] = true
+		return "*types.Address"
+	case "location":
+		g.imports[typesImport] = true
+		return "*types.Location"
+	case "anyType", "complexvalue":
+		return "interface{}"
+	}
+
+	var baseType string
+	switch field.Type {
+	case "boolean":
+		// checkboxes are never null
+		return "*bool"
+	case "int", "long":
+		baseType = "int64"
+	case "double", "currency", "percent":
+		baseType = "float64"
+	case "date":
+		baseType = "types.Date"
+	case "datetime":
+		baseType = "types.DateTime"
+	case "time":
+		baseType = "types.Time"
+	case "multipicklist":
+		baseType = "types.MultiPicklist"
+	case "id", "reference", "string", "textarea", "picklist", "combobox", "email", "phone", "url", "encryptedstring", "base64", "datacategorygroupreference":
+		// base64 fields are sent as the url of the blob
+		baseType = "string"
+	default:
+		return "interface{}"
+	}
+	if strings.HasPrefix(baseType, "types.") {
+		g.imports[typesImport] = true
+	}
+	if field.Nillable {
+		g.imports[typesImport] = true
+		return fmt.Sprintf("types.Nullable[%s]", baseType)
+	}
+	if baseType == "types.MultiPicklist" {
+		return baseType
+	}
+	return "*" + baseType
+}
+
+// fieldComment documents the targets of reference fields
+func fieldComment(field pkg.DescribeField) string {
+	if field.Type == "reference" {
+		return fmt.Sprintf("%s references %s", goName(field.Name), strings.Join(field.ReferenceTo, ", "))
+	}
+	return ""
+}
+
+// writePicklistConstants writes a constant for every value of a picklist
+func writePicklistConstants(out *bytes.Buffer, prefix string, objectName string, field pkg.DescribeField, usedNames map[string]bool) {
+	fmt.Fprintf(out, "// values of %s.%s\n", objectName, field.Name)
+	out.WriteString("const (\n")
+	for i, entry := range field.PicklistValues {
+		valueName := goName(entry.Value)
+		if valueName == "" {
+			valueName = fmt.Sprintf("Value%d", i)
+		}
+		fmt.Fprintf(out, "\t%s = %q\n", uniqueName(prefix+valueName, usedNames), entry.Value)
+	}
+	out.WriteString(")\n\n")
+}
+
+// goName converts a salesforce api name to an exported go identifier, e.g.
+// My_Field__c becomes MyField
+func goName(apiName string) string {
+	apiName = strings.TrimSuffix(apiName, "__c")
+	apiName = strings.TrimSuffix(apiName, "__r")
+	var name strings.Builder
+	upperNext := true
+	for _, r := range apiName {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		name.WriteRune(r)
+	}
+	result := name.String()
+	if result != "" && unicode.IsDigit(rune(result[0])) {
+		result = "X" + result
+	}
+	return result
+}
+
+// uniqueName returns name, or name with a numeric suffix if it was already
+// used, and marks the result as used
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+// sfgen generates go structs from salesforce sObject describes.
+//
+// describes are fetched from the org configured by the SALESFORCE_*
+// environment variables, or read from saved describe json files for offline
+// builds:
+//
+//	sfgen -types Account,Contact,My_Object__c -package sfmodel -out sfmodel/objects.go
+//	sfgen -types Account,Contact -describe-dir ./describes -package sfmodel -out sfmodel/objects.go
+//
+// with -save-describe-dir the fetched describes are written as <Type>.json,
+// so later builds can use -describe-dir.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/catalystcommunity/salesforce-utils/pkg"
+)
+
+func main() {
+	types := flag.String("types", "", "comma separated list of object types to generate")
+	packageName := flag.String("package", "sfmodel", "package name of the generated file")
+	out := flag.String("out", "", "file to write, stdout when empty")
+	describeDir := flag.String("describe-dir", "", "read describes from <dir>/<Type>.json instead of calling salesforce")
+	saveDescribeDir := flag.String("save-describe-dir", "", "write the fetched describes to <dir>/<Type>.json")
+	flag.Parse()
+
+	err := run(splitTypes(*types), *packageName, *out, *describeDir, *saveDescribeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sfgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(typeNames []string, packageName, out, describeDir, saveDescribeDir string) error {
+	if len(typeNames) == 0 {
+		return fmt.Errorf("-types must not be empty")
+	}
+	describes, err := loadDescribes(typeNames, describeDir)
+	if err != nil {
+		return err
+	}
+	if saveDescribeDir != "" {
+		err = saveDescribes(describes, saveDescribeDir)
+		if err != nil {
+			return err
+		}
+	}
+	source, err := generate(packageName, describes)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(out, source, 0o644)
+}
+
+func splitTypes(types string) []string {
+	var typeNames []string
+	for _, typeName := range strings.Split(types, ",") {
+		typeName = strings.TrimSpace(typeName)
+		if typeName != "" {
+			typeNames = append(typeNames, typeName)
+		}
+	}
+	return typeNames
+}
+
+// loadDescribes reads the describes from describeDir, or fetches them from
+// salesforce when describeDir is empty
+func loadDescribes(typeNames []string, describeDir string) ([]pkg.SObjectDescribe, error) {
+	describes := make([]pkg.SObjectDescribe, 0, len(typeNames))
+	if describeDir != "" {
+		for _, typeName := range typeNames {
+			body, err := os.ReadFile(filepath.Join(describeDir, typeName+".json"))
+			if err != nil {
+				return nil, err
+			}
+			var describe pkg.SObjectDescribe
+			err = json.Unmarshal(body, &describe)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal describe of %s: %w", typeName, err)
+			}
+			describes = append(describes, describe)
+		}
+		return describes, nil
+	}
+
+	sfUtils, err := pkg.NewSalesforceUtils(true, pkg.Config{})
+	if err != nil {
+		return nil, err
+	}
+	for _, typeName := range typeNames {
+		describe, err := sfUtils.DescribeObjectFull(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe %s: %w", typeName, err)
+		}
+		describes = append(describes, describe)
+	}
+	return describes, nil
+}
+
+func saveDescribes(describes []pkg.SObjectDescribe, dir string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	for _, describe := range describes {
+		body, err := json.MarshalIndent(describe, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(filepath.Join(dir, describe.Name+".json"), body, 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
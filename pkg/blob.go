@@ -0,0 +1,267 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// maximum blob sizes of a multipart upload
+const (
+	contentVersionMaxBlobSize = 2 << 30
+	defaultMaxBlobSize        = 500 << 20
+)
+
+// blobFields maps the object types that have a blob field to the field
+var blobFields = map[string]string{
+	"ContentVersion": "VersionData",
+	"Attachment":     "Body",
+	"Document":       "Body",
+}
+
+// ContentVersionInput is the metadata of a file uploaded with
+// CreateContentVersion
+type ContentVersionInput struct {
+	Title string `json:"Title,omitempty"`
+	// PathOnClient is the file name, its extension sets the file type
+	PathOnClient string `json:"PathOnClient"`
+	Description  string `json:"Description,omitempty"`
+	// FirstPublishLocationId links the file to a record as it's created
+	FirstPublishLocationId string `json:"FirstPublishLocationId,omitempty"`
+}
+
+// CreateContentVersion uploads a file as a new ContentVersion, which creates
+// its ContentDocument. size is the length of content, or -1 if it isn't
+// known, in which case the body is sent chunked and the upload fails once
+// content exceeds the 2GB limit.
+func (s *SalesforceUtils) CreateContentVersion(input ContentVersionInput, content io.Reader, size int64) (ObjectResponse, error) {
+	fields, err := json.Marshal(input)
+	if err != nil {
+		return ObjectResponse{}, err
+	}
+	return s.CreateObjectWithBlob("ContentVersion", fields, input.PathOnClient, content, size)
+}
+
+// CreateAttachment uploads a file as an Attachment of the parent record. see
+// CreateContentVersion for size.
+func (s *SalesforceUtils) CreateAttachment(parentId, name string, content io.Reader, size int64) (ObjectResponse, error) {
+	fields, err := json.Marshal(map[string]string{"ParentId": parentId, "Name": name})
+	if err != nil {
+		return ObjectResponse{}, err
+	}
+	return s.CreateObjectWithBlob("Attachment", fields, name, content, size)
+}
+
+// CreateDocument uploads a file as a Document in a folder. see
+// CreateContentVersion for size.
+func (s *SalesforceUtils) CreateDocument(folderId, name string, content io.Reader, size int64) (ObjectResponse, error) {
+	fields, err := json.Marshal(map[string]string{"FolderId": folderId, "Name": name})
+	if err != nil {
+		return ObjectResponse{}, err
+	}
+	return s.CreateObjectWithBlob("Document", fields, name, content, size)
+}
+
+// CreateObjectWithBlob creates a ContentVersion, Attachment or Document with
+// a multipart request, streaming the blob from content rather than reading
+// it into memory. jsonBytes holds the other fields of the record. size is the
+// length of content, or -1 if it isn't known. ContentVersion blobs can be up
+// to 2GB, the others up to 500MB.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/dome_sobject_insert_update_blob.htm
+func (s *SalesforceUtils) CreateObjectWithBlob(typeName string, jsonBytes []byte, fileName string, content io.Reader, size int64) (response ObjectResponse, err error) {
+	blobField, ok := blobFields[typeName]
+	if !ok {
+		return response, errorx.IllegalArgument.New("%s has no blob field", typeName)
+	}
+	maxSize := int64(defaultMaxBlobSize)
+	if typeName == "ContentVersion" {
+		maxSize = contentVersionMaxBlobSize
+	}
+	if size > maxSize {
+		return response, errorx.IllegalArgument.New("%s blobs must not be larger than %d bytes, got %d", typeName, maxSize, size)
+	}
+
+	prefix, suffix, contentType, err := blobMultipartFrame(typeName, blobField, jsonBytes, fileName)
+	if err != nil {
+		return response, err
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getTypeUrl(typeName))
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", contentType)
+	blob := &maxSizeReader{reader: content, remaining: maxSize, typeName: typeName}
+	body := io.MultiReader(bytes.NewReader(prefix), blob, bytes.NewReader(suffix))
+	if size >= 0 {
+		req.SetBodyStream(body, len(prefix)+int(size)+len(suffix))
+	} else {
+		req.SetBodyStream(body, -1)
+	}
+
+	resBody, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if blob.err != nil {
+		return response, blob.err
+	}
+	if err != nil {
+		return response, err
+	}
+	if statusCode != http.StatusCreated {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, resBody)
+	}
+	err = json.Unmarshal(resBody, &response)
+	return
+}
+
+// blobMultipartFrame builds the multipart body around the blob: prefix holds
+// the json part and the headers of the blob part, suffix closes the body
+func blobMultipartFrame(typeName, blobField string, jsonBytes []byte, fileName string) (prefix []byte, suffix []byte, contentType string, err error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	jsonHeader := textproto.MIMEHeader{}
+	jsonHeader.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": fmt.Sprintf("entity_%s", typeName)}))
+	jsonHeader.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(jsonHeader)
+	if err != nil {
+		return
+	}
+	_, err = part.Write(jsonBytes)
+	if err != nil {
+		return
+	}
+	blobHeader := textproto.MIMEHeader{}
+	// FormatMediaType quotes the file name, and encodes it when it has
+	// characters that can't be quoted such as line breaks
+	blobHeader.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": blobField, "filename": filepath.Base(fileName)}))
+	blobHeader.Set("Content-Type", "application/octet-stream")
+	_, err = writer.CreatePart(blobHeader)
+	if err != nil {
+		return
+	}
+	prefix = append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	err = writer.Close()
+	if err != nil {
+		return
+	}
+	return prefix, buffer.Bytes(), writer.FormDataContentType(), nil
+}
+
+// maxSizeReader fails once more than remaining bytes are read, so a blob of
+// unknown size can't exceed the upload limit. the error is kept because the
+// http client doesn't return errors from the body stream.
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+	typeName  string
+	err       error
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	n, err := m.reader.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		m.err = errorx.IllegalArgument.New("%s blob exceeds the upload limit", m.typeName)
+		return 0, m.err
+	}
+	if err != nil && err != io.EOF {
+		m.err = errorx.Decorate(err, "failed to read %s blob", m.typeName)
+		return n, m.err
+	}
+	return n, err
+}
+
+// DownloadContentVersion writes the file of a ContentVersion to w, returning
+// the number of bytes written
+func (s *SalesforceUtils) DownloadContentVersion(id string, w io.Writer) (int64, error) {
+	return s.DownloadBlob("ContentVersion", id, "VersionData", w)
+}
+
+// DownloadBlob writes the blob field of a record to w, e.g. the Body of an
+// Attachment, returning the number of bytes written. the request is sent
+// through s.StreamingHTTPClient and bodies larger than 64KB are streamed into
+// w, so large files are never held in memory.
+func (s *SalesforceUtils) DownloadBlob(typeName, id, blobField string, w io.Writer) (int64, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBlobUrl(typeName, id, blobField))
+	req.Header.SetMethod(http.MethodGet)
+	res, deferredFunc, err := s.sendStreamingRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return 0, err
+	}
+	switch res.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, NotFoundError.New("%s %s has no %s with body: %s", typeName, id, blobField, res.Body())
+	default:
+		return 0, errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+	}
+	written, err := io.Copy(w, res.BodyStream())
+	if err != nil {
+		return written, errorx.Decorate(err, "failed to download %s of %s %s", blobField, typeName, id)
+	}
+	return written, nil
+}
+
+// ContentDocumentLinkInput controls how LinkContentVersion shares a file
+type ContentDocumentLinkInput struct {
+	// ShareType is V (viewer), C (collaborator) or I (inferred from the
+	// record). defaults to V.
+	ShareType string
+	// Visibility is AllUsers, InternalUsers or SharedUsers. defaults to
+	// AllUsers.
+	Visibility string
+}
+
+// LinkContentVersion shares the file of a ContentVersion with records by
+// creating a ContentDocumentLink for each of them, returning one result per
+// record in order
+func (s *SalesforceUtils) LinkContentVersion(contentVersionId string, recordIds []string, input ContentDocumentLinkInput) ([]CollectionsResponseItem, error) {
+	var version struct {
+		ContentDocumentId string
+	}
+	err := s.GetObject("ContentVersion", contentVersionId, &version, "ContentDocumentId")
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to get the document of content version %s", contentVersionId)
+	}
+	if input.ShareType == "" {
+		input.ShareType = "V"
+	}
+	if input.Visibility == "" {
+		input.Visibility = "AllUsers"
+	}
+	records := make([][]byte, len(recordIds))
+	for i, recordId := range recordIds {
+		records[i], err = json.Marshal(map[string]interface{}{
+			"attributes":        map[string]string{"type": "ContentDocumentLink"},
+			"ContentDocumentId": version.ContentDocumentId,
+			"LinkedEntityId":    recordId,
+			"ShareType":         input.ShareType,
+			"Visibility":        input.Visibility,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.CollectionsCreateObjectsBatched(records, 1)
+}
+
+// getBlobUrl gets a formatted full url to the blob field of a record
+func (s *SalesforceUtils) getBlobUrl(typeName, id, blobField string) string {
+	return fmt.Sprintf("%s/%s", s.getObjectIdUrl(typeName, id), blobField)
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// releaseWriter counts the bytes written to it. on the first write it
+// records whether the server had finished sending and closes released.
+type releaseWriter struct {
+	once          sync.Once
+	released      chan struct{}
+	finished      *int32
+	releasedEarly bool
+	written       int
+}
+
+func (w *releaseWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		w.releasedEarly = atomic.LoadInt32(w.finished) == 0
+		close(w.released)
+	})
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestDownloadBlobStreamsLargeBodies(t *testing.T) {
+	const size = 8 * 1024 * 1024
+	half := bytes.Repeat([]byte("a"), size/2)
+	var finished int32
+	writer := &releaseWriter{released: make(chan struct{}), finished: &finished}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write(half)
+		w.(http.Flusher).Flush()
+		// the second half is only sent once the client has written some of
+		// the first half out, which it can't do if it buffers the body
+		select {
+		case <-writer.released:
+		case <-time.After(5 * time.Second):
+		}
+		atomic.StoreInt32(&finished, 1)
+		_, _ = w.Write(half)
+	}))
+	defer server.Close()
+
+	s, err := NewSalesforceUtils(false, Config{
+		BaseUrl:      server.URL,
+		ApiVersion:   "55.0",
+		ClientId:     "client",
+		ClientSecret: "secret",
+		Username:     "user",
+		Password:     "password",
+		GrantType:    "password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := s.DownloadContentVersion("068000000000001", writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != size || writer.written != size {
+		t.Fatalf("expected %d bytes, got %d written and %d returned", size, writer.written, written)
+	}
+	if !writer.releasedEarly {
+		t.Fatal("the body was buffered before it was written")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/joomcode/errorx"
 	"github.com/valyala/fasthttp"
@@ -25,10 +26,23 @@ type BulkJobRecord struct {
 	LineEnding                      string  `json:"lineEnding"`
 	ColumnDelimiter                 string  `json:"columnDelimiter"`
 	NumberRecordsProcessed          int64   `json:"numberRecordsProcessed"`
+	NumberRecordsFailed             int64   `json:"numberRecordsFailed"`
 	Retries                         int64   `json:"retries"`
 	TotalProcessingTimeMilliseconds int64   `json:"totalProcessingTime"`
+	ErrorMessage                    string  `json:"errorMessage"`
+	ExternalIdFieldName             string  `json:"externalIdFieldName"`
 }
 
+// states a bulk 2.0 job can be in, as reported in BulkJobRecord.State
+const (
+	BulkJobStateOpen           = "Open"
+	BulkJobStateUploadComplete = "UploadComplete"
+	BulkJobStateInProgress     = "InProgress"
+	BulkJobStateAborted        = "Aborted"
+	BulkJobStateJobComplete    = "JobComplete"
+	BulkJobStateFailed         = "Failed"
+)
+
 func (s *SalesforceUtils) CreateBulkQueryJob(query string) (BulkJobRecord, error) {
 	return s.createBulkJob(bulkJobOperationQuery, query)
 }
@@ -91,6 +105,10 @@ func (s *SalesforceUtils) GetBulkQueryJob(queryJobID string) (response BulkJobRe
 		err = requestErr
 		return
 	}
+	if statusCode == http.StatusNotFound {
+		err = NotFoundError.New("bulk query job %s not found with body: %s", queryJobID, body)
+		return
+	}
 	if statusCode != http.StatusOK {
 		err = errorx.Decorate(err, "unexpected status code: %d with body: %s", statusCode, body)
 		return
@@ -99,10 +117,64 @@ func (s *SalesforceUtils) GetBulkQueryJob(queryJobID string) (response BulkJobRe
 	return
 }
 
+// AbortBulkQueryJob aborts a query job that hasn't finished yet
+func (s *SalesforceUtils) AbortBulkQueryJob(queryJobID string) (response BulkJobRecord, err error) {
+	body, err := json.Marshal(map[string]string{"state": BulkJobStateAborted})
+	if err != nil {
+		err = errorx.Decorate(err, "failed to marshal job state")
+		return
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkQueryJobInfoUrl(queryJobID))
+	req.Header.SetMethod(http.MethodPatch)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(body)
+	responseBody, statusCode, deferredFunc, requestErr := s.sendRequest(req)
+	defer deferredFunc()
+	if requestErr != nil {
+		err = requestErr
+		return
+	}
+	if statusCode != http.StatusOK {
+		err = errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, responseBody)
+		return
+	}
+	err = json.Unmarshal(responseBody, &response)
+	return
+}
+
 func (s *SalesforceUtils) getBulkQueryJobInfoUrl(queryJobID string) string {
 	return fmt.Sprintf("%s/%s", s.getBulkUrl(), queryJobID)
 }
 
+// WaitForBulkQueryJob polls the bulk query job every pollInterval until it
+// reaches a terminal state. an error is returned if the job was aborted or
+// failed, along with the last job record that was read. a timeout greater
+// than zero stops waiting with an errorx.TimeoutElapsed error once it has
+// passed, the job itself is left running.
+func (s *SalesforceUtils) WaitForBulkQueryJob(queryJobID string, pollInterval time.Duration, timeout time.Duration) (response BulkJobRecord, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		response, err = s.GetBulkQueryJob(queryJobID)
+		if err != nil {
+			return
+		}
+		switch response.State {
+		case BulkJobStateJobComplete:
+			return
+		case BulkJobStateAborted, BulkJobStateFailed:
+			err = errorx.IllegalState.New("bulk query job %s ended in state %s: %s", queryJobID, response.State, response.ErrorMessage)
+			return
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			err = errorx.TimeoutElapsed.New("bulk query job %s still in state %s after %s", queryJobID, response.State, timeout)
+			return
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
 type GetBulkQueryJobResultsResponse struct {
 	NumberOfRecords int
 	Locator         string
@@ -137,7 +209,8 @@ func (s *SalesforceUtils) GetBulkQueryJobResults(queryJobID string, locator stri
 
 	response.Locator = locator
 	response.NumberOfRecords, _ = strconv.Atoi(string(res.Header.Peek("Sforce-NumberOfRecords")))
-	response.Body = res.Body()
+	// copy the body, the response is released when this method returns
+	response.Body = append([]byte(nil), res.Body()...)
 	return
 }
 
@@ -0,0 +1,267 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// bulkJobDateLayout is the layout of the dates in BulkJobRecord
+const bulkJobDateLayout = "2006-01-02T15:04:05.000-0700"
+
+// BulkExportCheckpoint is the progress of a bulk export, saved after every
+// page of results so an interrupted export can pick up where it left off
+type BulkExportCheckpoint struct {
+	JobID string `json:"jobId"`
+	// Query is the soql the job was created for, so a checkpoint is never
+	// resumed for a different query
+	Query          string `json:"query"`
+	Locator        string `json:"locator"`
+	RecordsWritten int64  `json:"recordsWritten"`
+	// BytesWritten is the size of the output up to and including the last
+	// page covered by the checkpoint
+	BytesWritten int64     `json:"bytesWritten"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// BulkCheckpointStore persists export checkpoints by key. implementations
+// must return a nil checkpoint and a nil error from LoadCheckpoint when there
+// is no checkpoint for the key.
+type BulkCheckpointStore interface {
+	LoadCheckpoint(key string) (*BulkExportCheckpoint, error)
+	SaveCheckpoint(key string, checkpoint BulkExportCheckpoint) error
+	DeleteCheckpoint(key string) error
+}
+
+// FileBulkCheckpointStore is a BulkCheckpointStore that keeps one json file
+// per key in a directory
+type FileBulkCheckpointStore struct {
+	Dir string
+}
+
+// NewFileBulkCheckpointStore creates a FileBulkCheckpointStore, creating the
+// directory if it doesn't exist
+func NewFileBulkCheckpointStore(dir string) (*FileBulkCheckpointStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to create checkpoint directory")
+	}
+	return &FileBulkCheckpointStore{Dir: dir}, nil
+}
+
+func (f *FileBulkCheckpointStore) LoadCheckpoint(key string) (*BulkExportCheckpoint, error) {
+	return loadJsonFile[BulkExportCheckpoint](f.path(key))
+}
+
+func (f *FileBulkCheckpointStore) SaveCheckpoint(key string, checkpoint BulkExportCheckpoint) error {
+	return saveJsonFile(f.path(key), checkpoint)
+}
+
+func (f *FileBulkCheckpointStore) DeleteCheckpoint(key string) error {
+	err := os.Remove(f.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errorx.Decorate(err, "failed to delete checkpoint")
+	}
+	return nil
+}
+
+// path gets the file path for a key, escaping it so any key is a valid file
+// name
+func (f *FileBulkCheckpointStore) path(key string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s.json", url.PathEscape(key)))
+}
+
+// loadJsonFile reads and unmarshals a json file, returning nil if the file
+// doesn't exist
+func loadJsonFile[T any](path string) (*T, error) {
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to read %s", path)
+	}
+	value := new(T)
+	err = json.Unmarshal(body, value)
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to unmarshal %s", path)
+	}
+	return value, nil
+}
+
+// saveJsonFile marshals value to a json file. the file is written to a
+// temporary file first and renamed, so a crash never leaves a partial file.
+func saveJsonFile(path string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return errorx.Decorate(err, "failed to marshal %s", path)
+	}
+	tmpPath := fmt.Sprintf("%s.tmp", path)
+	err = os.WriteFile(tmpPath, body, 0o644)
+	if err != nil {
+		return errorx.Decorate(err, "failed to write %s", tmpPath)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return errorx.Decorate(err, "failed to rename %s", tmpPath)
+	}
+	return nil
+}
+
+// BulkExportOptions controls how ExportBulkQuery runs. zero values are
+// replaced with sensible defaults.
+type BulkExportOptions struct {
+	// QueryAll includes deleted and archived records when true
+	QueryAll bool
+	// PollInterval is how often the job is polled for completion. defaults
+	// to 5 seconds.
+	PollInterval time.Duration
+	// JobTimeout is how long to wait for the job to complete before giving
+	// up. the checkpoint is kept, so the export can be resumed later.
+	// defaults to waiting forever.
+	JobTimeout time.Duration
+	// RetentionWindow is how long salesforce keeps the results of a job.
+	// a checkpoint for a job older than this is discarded. defaults to 7
+	// days.
+	RetentionWindow time.Duration
+}
+
+// BulkExportResult is the outcome of ExportBulkQuery
+type BulkExportResult struct {
+	JobID          string
+	RecordsWritten int64
+	BytesWritten   int64
+	// Resumed is true when an existing job was resumed from a checkpoint
+	Resumed bool
+}
+
+// ExportBulkQuery runs a bulk query and writes every page of csv results to
+// the writer returned by openOutput, saving a checkpoint to store under key
+// after each page. if a checkpoint exists for key and its job is still within
+// the retention window, the export resumes from the checkpoint's locator
+// instead of creating a new job.
+//
+// openOutput is called once before anything is written. offset is the number
+// of bytes of output covered by the checkpoint, or 0 for a new export. the
+// output must be truncated to offset and written from there, since a page is
+// written before its checkpoint is saved and a page that was being written
+// when the export died is written again on resume. a single header row is
+// written across all pages.
+//
+// an error is returned if the checkpoint for key was saved for a different
+// query. the checkpoint is deleted once the last page is written.
+func (s *SalesforceUtils) ExportBulkQuery(key string, query string, store BulkCheckpointStore, openOutput func(offset int64) (io.Writer, error), options BulkExportOptions) (result BulkExportResult, err error) {
+	if options.PollInterval <= 0 {
+		options.PollInterval = 5 * time.Second
+	}
+	if options.RetentionWindow <= 0 {
+		options.RetentionWindow = 7 * 24 * time.Hour
+	}
+
+	checkpoint, err := s.loadResumableCheckpoint(key, query, store, options.RetentionWindow)
+	if err != nil {
+		return
+	}
+	result.Resumed = checkpoint != nil
+	if checkpoint == nil {
+		var job BulkJobRecord
+		if options.QueryAll {
+			job, err = s.CreateBulkQueryAllJob(query)
+		} else {
+			job, err = s.CreateBulkQueryJob(query)
+		}
+		if err != nil {
+			return
+		}
+		checkpoint = &BulkExportCheckpoint{JobID: job.ID, Query: query, UpdatedAt: time.Now()}
+		err = store.SaveCheckpoint(key, *checkpoint)
+		if err != nil {
+			return
+		}
+	}
+	result.JobID = checkpoint.JobID
+	result.RecordsWritten = checkpoint.RecordsWritten
+	result.BytesWritten = checkpoint.BytesWritten
+
+	w, err := openOutput(checkpoint.BytesWritten)
+	if err != nil {
+		err = errorx.Decorate(err, "failed to open export output")
+		return
+	}
+
+	_, err = s.WaitForBulkQueryJob(checkpoint.JobID, options.PollInterval, options.JobTimeout)
+	if err != nil {
+		return
+	}
+
+	for {
+		page, pageErr := s.GetBulkQueryJobResults(checkpoint.JobID, checkpoint.Locator)
+		if pageErr != nil {
+			err = pageErr
+			return
+		}
+		body := page.Body
+		// only the first page keeps its header row
+		if checkpoint.Locator != "" {
+			body = stripCsvHeader(body)
+		}
+		n, writeErr := w.Write(body)
+		checkpoint.BytesWritten += int64(n)
+		result.BytesWritten = checkpoint.BytesWritten
+		if writeErr != nil {
+			err = errorx.Decorate(writeErr, "failed to write export page")
+			return
+		}
+		checkpoint.RecordsWritten += int64(page.NumberOfRecords)
+		result.RecordsWritten = checkpoint.RecordsWritten
+		if page.Locator == "" {
+			break
+		}
+		checkpoint.Locator = page.Locator
+		checkpoint.UpdatedAt = time.Now()
+		err = store.SaveCheckpoint(key, *checkpoint)
+		if err != nil {
+			return
+		}
+	}
+	err = store.DeleteCheckpoint(key)
+	return
+}
+
+// loadResumableCheckpoint loads the checkpoint for key, returning nil if there
+// is none or if its job no longer exists or can no longer be resumed. any
+// other failure to check the job is returned rather than starting a new one.
+func (s *SalesforceUtils) loadResumableCheckpoint(key string, query string, store BulkCheckpointStore, retentionWindow time.Duration) (*BulkExportCheckpoint, error) {
+	checkpoint, err := store.LoadCheckpoint(key)
+	if err != nil || checkpoint == nil {
+		return nil, err
+	}
+	if checkpoint.Query != query {
+		return nil, errorx.IllegalState.New("checkpoint %s is for job %s with a different query: %s", key, checkpoint.JobID, checkpoint.Query)
+	}
+	job, err := s.GetBulkQueryJob(checkpoint.JobID)
+	if errorx.IsOfType(err, NotFoundError) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to get job %s for checkpoint %s", checkpoint.JobID, key)
+	}
+	if job.State == BulkJobStateAborted || job.State == BulkJobStateFailed {
+		return nil, nil
+	}
+	createdDate, err := time.Parse(bulkJobDateLayout, job.CreatedDate)
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to parse created date of job %s", checkpoint.JobID)
+	}
+	if time.Since(createdDate) > retentionWindow {
+		return nil, nil
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,502 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// BulkChunkedQuery describes a bulk query that is exported in Id range
+// windows rather than as a single job. each window becomes its own bulk query
+// job with an Id range predicate appended to Where, which means Where must not
+// contain ORDER BY, LIMIT or any other trailing clause.
+type BulkChunkedQuery struct {
+	// ObjectType is the salesforce object type, e.g. "Account"
+	ObjectType string
+	// Fields are the fields to select
+	Fields []string
+	// Where is an optional SOQL condition, without the WHERE keyword
+	Where string
+	// QueryAll includes deleted and archived records when true
+	QueryAll bool
+}
+
+// BulkChunkedQueryOptions controls how a BulkChunkedQuery is executed. zero
+// values are replaced with sensible defaults.
+type BulkChunkedQueryOptions struct {
+	// ChunkCount is the number of Id windows the query is split into.
+	// defaults to 10.
+	ChunkCount int
+	// MaxConcurrency is the maximum number of chunk jobs running at the same
+	// time. defaults to 4.
+	MaxConcurrency int
+	// MaxRetries is the number of times a failed chunk is retried before the
+	// export gives up on it. defaults to 2, set it to a negative value to
+	// disable retries.
+	MaxRetries int
+	// PollInterval is how often chunk jobs are polled for completion.
+	// defaults to 5 seconds.
+	PollInterval time.Duration
+	// JobTimeout is how long a chunk job may run before it's aborted and
+	// retried. defaults to waiting forever.
+	JobTimeout time.Duration
+	// TempDir is where the results of completed chunks are kept until every
+	// chunk before them has been written. defaults to os.TempDir().
+	TempDir string
+	// OnProgress, if set, is called every time a chunk changes state. it may
+	// be called from multiple goroutines at once.
+	OnProgress func(BulkChunkProgress)
+}
+
+// BulkChunkState is the state of a single chunk reported through
+// BulkChunkedQueryOptions.OnProgress
+type BulkChunkState string
+
+const (
+	BulkChunkStateStarted   BulkChunkState = "Started"
+	BulkChunkStateRetrying  BulkChunkState = "Retrying"
+	BulkChunkStateCompleted BulkChunkState = "Completed"
+	BulkChunkStateFailed    BulkChunkState = "Failed"
+)
+
+// BulkChunkProgress is a progress report for a single chunk
+type BulkChunkProgress struct {
+	ChunkIndex      int
+	ChunkCount      int
+	State           BulkChunkState
+	Attempt         int
+	JobID           string
+	NumberOfRecords int
+	Err             error
+}
+
+// BulkChunkResult is the outcome of a single chunk of a chunked bulk query.
+// an empty LowerBoundId or UpperBoundId means the window is unbounded on that
+// side.
+type BulkChunkResult struct {
+	Index           int
+	LowerBoundId    string
+	UpperBoundId    string
+	JobID           string
+	NumberOfRecords int
+	Attempts        int
+	Err             error
+}
+
+// ExecuteChunkedBulkQuery exports the query in Id range windows, one bulk
+// query job per window, in the same way salesforce pk chunking splits a
+// query. the window boundaries are interpolated between the lowest and highest
+// Id of the object type, so the windows are even in Id space rather than in
+// record count.
+//
+// chunk jobs run concurrently up to options.MaxConcurrency and each chunk is
+// retried individually. the csv results are written to w in Id order with a
+// single header row. a completed chunk is kept in a temporary file in
+// options.TempDir until every chunk before it has been written, so only a
+// page per running chunk is held in memory. if a chunk fails after all of its
+// retries, nothing from that chunk onwards is written and an error is
+// returned along with the per-chunk results.
+func (s *SalesforceUtils) ExecuteChunkedBulkQuery(query BulkChunkedQuery, w io.Writer, options BulkChunkedQueryOptions) ([]BulkChunkResult, error) {
+	if query.ObjectType == "" || len(query.Fields) == 0 {
+		return nil, errorx.IllegalArgument.New("object type and fields are required")
+	}
+	options = options.withDefaults()
+
+	boundaries, err := s.getIdBoundaries(query, options.ChunkCount)
+	if err != nil {
+		return nil, err
+	}
+	if boundaries == nil {
+		// no records
+		return []BulkChunkResult{}, nil
+	}
+
+	results := make([]BulkChunkResult, len(boundaries)+1)
+	for i := range results {
+		if i > 0 {
+			results[i].LowerBoundId = boundaries[i-1]
+		}
+		if i < len(boundaries) {
+			results[i].UpperBoundId = boundaries[i]
+		}
+		results[i].Index = i
+	}
+
+	writer := newOrderedChunkWriter(w, len(results))
+	forEachConcurrently(len(results), options.MaxConcurrency, func(i int) {
+		output := s.runBulkQueryChunk(query, &results[i], len(results), options)
+		writer.complete(i, output)
+	})
+	writer.discardPending()
+
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, fmt.Sprintf("chunk %d: %s", result.Index, result.Err))
+		}
+	}
+	if len(failed) > 0 {
+		return results, errorx.IllegalState.New("%d of %d chunks failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	if writer.err != nil {
+		return results, errorx.Decorate(writer.err, "failed to write chunk results")
+	}
+	return results, nil
+}
+
+func (o BulkChunkedQueryOptions) withDefaults() BulkChunkedQueryOptions {
+	if o.ChunkCount < 1 {
+		o.ChunkCount = 10
+	}
+	if o.MaxConcurrency < 1 {
+		o.MaxConcurrency = 4
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = 2
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 5 * time.Second
+	}
+	return o
+}
+
+// runBulkQueryChunk runs the bulk query job for a single chunk, retrying it
+// until it succeeds or runs out of retries. the outcome is recorded on result
+// and the results are returned, or nil if the chunk failed. the job of a
+// failed attempt is aborted before it's retried.
+func (s *SalesforceUtils) runBulkQueryChunk(query BulkChunkedQuery, result *BulkChunkResult, chunkCount int, options BulkChunkedQueryOptions) *bulkChunkOutput {
+	report := func(state BulkChunkState) {
+		if options.OnProgress == nil {
+			return
+		}
+		options.OnProgress(BulkChunkProgress{
+			ChunkIndex:      result.Index,
+			ChunkCount:      chunkCount,
+			State:           state,
+			Attempt:         result.Attempts,
+			JobID:           result.JobID,
+			NumberOfRecords: result.NumberOfRecords,
+			Err:             result.Err,
+		})
+	}
+
+	soql := query.chunkSoql(result.LowerBoundId, result.UpperBoundId)
+	for {
+		result.Attempts++
+		result.Err = nil
+		result.NumberOfRecords = 0
+		report(BulkChunkStateStarted)
+
+		var job BulkJobRecord
+		if query.QueryAll {
+			job, result.Err = s.CreateBulkQueryAllJob(soql)
+		} else {
+			job, result.Err = s.CreateBulkQueryJob(soql)
+		}
+		result.JobID = job.ID
+		var output *bulkChunkOutput
+		if result.Err == nil {
+			_, result.Err = s.WaitForBulkQueryJob(job.ID, options.PollInterval, options.JobTimeout)
+		}
+		if result.Err == nil {
+			output, result.NumberOfRecords, result.Err = s.saveBulkQueryJobResults(job.ID, options.TempDir)
+		}
+		if result.Err == nil {
+			report(BulkChunkStateCompleted)
+			return output
+		}
+		if job.ID != "" {
+			// the job may still be running, e.g. after a timeout. this fails
+			// harmlessly if it already finished.
+			_, _ = s.AbortBulkQueryJob(job.ID)
+		}
+		if result.Attempts > options.MaxRetries {
+			report(BulkChunkStateFailed)
+			return nil
+		}
+		report(BulkChunkStateRetrying)
+	}
+}
+
+// bulkChunkOutput is the csv of a completed chunk. the rows are kept in a
+// temporary file so that chunks waiting on earlier chunks aren't held in
+// memory.
+type bulkChunkOutput struct {
+	header []byte
+	path   string
+}
+
+// remove deletes the temporary file
+func (o *bulkChunkOutput) remove() {
+	_ = os.Remove(o.path)
+}
+
+// saveBulkQueryJobResults follows the result locators of a completed bulk
+// query job, writing every page of csv rows to a temporary file in tempDir.
+// the total number of records is returned along with the output.
+func (s *SalesforceUtils) saveBulkQueryJobResults(queryJobID string, tempDir string) (output *bulkChunkOutput, numberOfRecords int, err error) {
+	file, err := os.CreateTemp(tempDir, "bulk-chunk-*.csv")
+	if err != nil {
+		return nil, 0, errorx.Decorate(err, "failed to create chunk file")
+	}
+	output = &bulkChunkOutput{path: file.Name()}
+	defer func() {
+		closeErr := file.Close()
+		if err == nil && closeErr != nil {
+			err = errorx.Decorate(closeErr, "failed to close chunk file")
+		}
+		if err != nil {
+			output.remove()
+			output = nil
+		}
+	}()
+
+	locator := ""
+	for {
+		page, pageErr := s.GetBulkQueryJobResults(queryJobID, locator)
+		if pageErr != nil {
+			return output, 0, pageErr
+		}
+		if output.header == nil {
+			output.header = csvHeader(page.Body)
+		}
+		_, err = file.Write(stripCsvHeader(page.Body))
+		if err != nil {
+			return output, 0, errorx.Decorate(err, "failed to write chunk file")
+		}
+		numberOfRecords += page.NumberOfRecords
+		if page.Locator == "" {
+			return output, numberOfRecords, nil
+		}
+		locator = page.Locator
+	}
+}
+
+// chunkSoql builds the SOQL for a single Id window
+func (q BulkChunkedQuery) chunkSoql(lowerBoundId, upperBoundId string) string {
+	var conditions []string
+	if q.Where != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s)", q.Where))
+	}
+	if lowerBoundId != "" {
+		conditions = append(conditions, fmt.Sprintf("Id >= '%s'", lowerBoundId))
+	}
+	if upperBoundId != "" {
+		conditions = append(conditions, fmt.Sprintf("Id < '%s'", upperBoundId))
+	}
+	soql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(q.Fields, ", "), q.ObjectType)
+	if len(conditions) > 0 {
+		soql = fmt.Sprintf("%s WHERE %s", soql, strings.Join(conditions, " AND "))
+	}
+	return soql
+}
+
+// getIdBoundaries looks up the lowest and highest Id matching the query and
+// returns up to chunkCount-1 Ids evenly spaced between them. nil is returned
+// if the object has no records.
+func (s *SalesforceUtils) getIdBoundaries(query BulkChunkedQuery, chunkCount int) ([]string, error) {
+	minId, err := s.getEdgeId(query, "ASC")
+	if err != nil || minId == "" {
+		return nil, err
+	}
+	maxId, err := s.getEdgeId(query, "DESC")
+	if err != nil {
+		return nil, err
+	}
+	return interpolateIds(minId, maxId, chunkCount), nil
+}
+
+// getEdgeId returns the first Id matching the query in the given sort order
+func (s *SalesforceUtils) getEdgeId(query BulkChunkedQuery, direction string) (string, error) {
+	soql := fmt.Sprintf("SELECT Id FROM %s", query.ObjectType)
+	if query.Where != "" {
+		soql = fmt.Sprintf("%s WHERE %s", soql, query.Where)
+	}
+	soql = fmt.Sprintf("%s ORDER BY Id %s LIMIT 1", soql, direction)
+	var response SoqlResponse
+	var err error
+	if query.QueryAll {
+		response, err = s.ExecuteSoqlQueryAll(soql)
+	} else {
+		response, err = s.ExecuteSoqlQuery(soql)
+	}
+	if err != nil {
+		return "", errorx.Decorate(err, "failed to look up %s id boundary", query.ObjectType)
+	}
+	if len(response.Records) == 0 {
+		return "", nil
+	}
+	record, ok := response.Records[0].(map[string]interface{})
+	if !ok {
+		return "", errorx.IllegalFormat.New("unexpected record in id boundary response: %v", response.Records[0])
+	}
+	id, _ := record["Id"].(string)
+	return id, nil
+}
+
+// salesforce ids are base62 encoded using this alphabet, which sorts in the
+// same order as the ids themselves
+const idAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// idPrefixLength is the length of the key prefix that every id of an object
+// type shares
+const idPrefixLength = 3
+
+// interpolateIds returns up to count-1 distinct 15 character ids spread evenly
+// between minId and maxId
+func interpolateIds(minId, maxId string, count int) []string {
+	minId = truncateId(minId)
+	maxId = truncateId(maxId)
+	boundaries := []string{}
+	if len(minId) != 15 || len(maxId) != 15 {
+		return boundaries
+	}
+	low := decodeIdSuffix(minId[idPrefixLength:])
+	high := decodeIdSuffix(maxId[idPrefixLength:])
+	span := new(big.Int).Sub(high, low)
+	for i := 1; i < count; i++ {
+		offset := new(big.Int).Mul(span, big.NewInt(int64(i)))
+		offset.Div(offset, big.NewInt(int64(count)))
+		boundary := minId[:idPrefixLength] + encodeIdSuffix(new(big.Int).Add(low, offset), 15-idPrefixLength)
+		if boundary <= minId || (len(boundaries) > 0 && boundary == boundaries[len(boundaries)-1]) {
+			continue
+		}
+		boundaries = append(boundaries, boundary)
+	}
+	return boundaries
+}
+
+// truncateId drops the case-safe checksum from an 18 character id
+func truncateId(id string) string {
+	if len(id) == 18 {
+		return id[:15]
+	}
+	return id
+}
+
+func decodeIdSuffix(suffix string) *big.Int {
+	value := new(big.Int)
+	base := big.NewInt(int64(len(idAlphabet)))
+	for _, c := range suffix {
+		value.Mul(value, base)
+		value.Add(value, big.NewInt(int64(strings.IndexRune(idAlphabet, c))))
+	}
+	return value
+}
+
+func encodeIdSuffix(value *big.Int, length int) string {
+	encoded := make([]byte, length)
+	base := big.NewInt(int64(len(idAlphabet)))
+	remaining := new(big.Int).Set(value)
+	digit := new(big.Int)
+	for i := length - 1; i >= 0; i-- {
+		remaining.DivMod(remaining, base, digit)
+		encoded[i] = idAlphabet[digit.Int64()]
+	}
+	return string(encoded)
+}
+
+// orderedChunkWriter writes the csv of completed chunks to the underlying
+// writer in chunk order, keeping only the first header row
+type orderedChunkWriter struct {
+	mu            sync.Mutex
+	w             io.Writer
+	next          int
+	pending       map[int]*bulkChunkOutput
+	stopped       bool
+	headerWritten bool
+	err           error
+}
+
+func newOrderedChunkWriter(w io.Writer, count int) *orderedChunkWriter {
+	return &orderedChunkWriter{w: w, pending: make(map[int]*bulkChunkOutput, count)}
+}
+
+// complete records the output of a chunk, nil if it failed, and writes every
+// chunk that is now next in line. a failed chunk stops all further writes.
+func (o *orderedChunkWriter) complete(index int, output *bulkChunkOutput) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopped {
+		if output != nil {
+			output.remove()
+		}
+		return
+	}
+	o.pending[index] = output
+	for !o.stopped {
+		output, ok := o.pending[o.next]
+		if !ok {
+			return
+		}
+		delete(o.pending, o.next)
+		if output == nil {
+			o.stopped = true
+			return
+		}
+		o.err = o.writeChunk(output)
+		output.remove()
+		if o.err != nil {
+			o.stopped = true
+			return
+		}
+		o.next++
+	}
+}
+
+// discardPending removes the files of chunks that were never written because
+// an earlier chunk failed
+func (o *orderedChunkWriter) discardPending() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for index, output := range o.pending {
+		if output != nil {
+			output.remove()
+		}
+		delete(o.pending, index)
+	}
+}
+
+func (o *orderedChunkWriter) writeChunk(output *bulkChunkOutput) error {
+	if !o.headerWritten && len(output.header) > 0 {
+		_, err := o.w.Write(output.header)
+		if err != nil {
+			return err
+		}
+		o.headerWritten = true
+	}
+	file, err := os.Open(output.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(o.w, file)
+	return err
+}
+
+// csvHeader gets the first line of a csv page, including its line ending
+func csvHeader(page []byte) []byte {
+	newline := bytes.IndexByte(page, '\n')
+	if newline < 0 {
+		return page
+	}
+	return page[:newline+1]
+}
+
+// stripCsvHeader drops the first line of a csv page
+func stripCsvHeader(page []byte) []byte {
+	newline := bytes.IndexByte(page, '\n')
+	if newline < 0 {
+		return nil
+	}
+	return page[newline+1:]
+}
@@ -0,0 +1,202 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// operations supported by bulk 2.0 ingest jobs
+const (
+	BulkIngestOperationInsert     = "insert"
+	BulkIngestOperationUpdate     = "update"
+	BulkIngestOperationUpsert     = "upsert"
+	BulkIngestOperationDelete     = "delete"
+	BulkIngestOperationHardDelete = "hardDelete"
+)
+
+// BulkIngestJobRequest is the body used to create a bulk 2.0 ingest job
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_asynch.meta/api_asynch/create_job.htm
+type BulkIngestJobRequest struct {
+	// Object is the salesforce object type, e.g. "Account"
+	Object string `json:"object"`
+	// Operation is one of the BulkIngestOperation constants
+	Operation string `json:"operation"`
+	// ExternalIdFieldName is required for upserts
+	ExternalIdFieldName string `json:"externalIdFieldName,omitempty"`
+	// AssignmentRuleId is the optional assignment rule for Case or Lead
+	AssignmentRuleId string `json:"assignmentRuleId,omitempty"`
+	// ColumnDelimiter defaults to COMMA
+	ColumnDelimiter string `json:"columnDelimiter,omitempty"`
+	// LineEnding defaults to LF
+	LineEnding string `json:"lineEnding,omitempty"`
+}
+
+// CreateBulkIngestJob creates a bulk 2.0 ingest job. data is added with
+// UploadBulkIngestJobData and the job is queued for processing with
+// CloseBulkIngestJob.
+func (s *SalesforceUtils) CreateBulkIngestJob(job BulkIngestJobRequest) (response BulkJobRecord, err error) {
+	if job.ColumnDelimiter == "" {
+		job.ColumnDelimiter = "COMMA"
+	}
+	if job.LineEnding == "" {
+		job.LineEnding = "LF"
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		err = errorx.Decorate(err, "failed to marshal job")
+		return
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkIngestUrl())
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(body)
+	err = s.doBulkIngestJobRequest(req, &response)
+	return
+}
+
+// UploadBulkIngestJobData uploads the csv data for an open ingest job. the
+// csv must have a header row and salesforce limits the upload to 150MB after
+// base64 encoding, which is roughly 100MB of raw csv.
+func (s *SalesforceUtils) UploadBulkIngestJobData(jobID string, csv []byte) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkIngestJobBatchesUrl(jobID))
+	req.Header.SetMethod(http.MethodPut)
+	req.Header.Set("Content-Type", "text/csv")
+	req.SetBody(csv)
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusCreated {
+		return errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	return nil
+}
+
+// CloseBulkIngestJob marks the upload of an ingest job as complete, which
+// queues the job for processing
+func (s *SalesforceUtils) CloseBulkIngestJob(jobID string) (BulkJobRecord, error) {
+	return s.setBulkIngestJobState(jobID, BulkJobStateUploadComplete)
+}
+
+// AbortBulkIngestJob aborts an ingest job
+func (s *SalesforceUtils) AbortBulkIngestJob(jobID string) (BulkJobRecord, error) {
+	return s.setBulkIngestJobState(jobID, BulkJobStateAborted)
+}
+
+func (s *SalesforceUtils) setBulkIngestJobState(jobID string, state string) (response BulkJobRecord, err error) {
+	body, err := json.Marshal(map[string]string{"state": state})
+	if err != nil {
+		err = errorx.Decorate(err, "failed to marshal job state")
+		return
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkIngestJobUrl(jobID))
+	req.Header.SetMethod(http.MethodPatch)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(body)
+	err = s.doBulkIngestJobRequest(req, &response)
+	return
+}
+
+// GetBulkIngestJob gets the current job info for an ingest job
+func (s *SalesforceUtils) GetBulkIngestJob(jobID string) (response BulkJobRecord, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkIngestJobUrl(jobID))
+	req.Header.SetMethod(http.MethodGet)
+	err = s.doBulkIngestJobRequest(req, &response)
+	return
+}
+
+// WaitForBulkIngestJob polls the ingest job every pollInterval until it
+// reaches a terminal state. an error is returned if the job was aborted or
+// failed, along with the last job record that was read.
+func (s *SalesforceUtils) WaitForBulkIngestJob(jobID string, pollInterval time.Duration) (response BulkJobRecord, err error) {
+	for {
+		response, err = s.GetBulkIngestJob(jobID)
+		if err != nil {
+			return
+		}
+		switch response.State {
+		case BulkJobStateJobComplete:
+			return
+		case BulkJobStateAborted, BulkJobStateFailed:
+			err = errorx.IllegalState.New("bulk ingest job %s ended in state %s: %s", jobID, response.State, response.ErrorMessage)
+			return
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// GetBulkIngestJobSuccessfulResults gets the csv of successfully processed
+// records. each row has sf__Id and sf__Created columns followed by the
+// uploaded columns.
+func (s *SalesforceUtils) GetBulkIngestJobSuccessfulResults(jobID string) ([]byte, error) {
+	return s.getBulkIngestJobResults(jobID, "successfulResults")
+}
+
+// GetBulkIngestJobFailedResults gets the csv of records that failed. each row
+// has sf__Id and sf__Error columns followed by the uploaded columns.
+func (s *SalesforceUtils) GetBulkIngestJobFailedResults(jobID string) ([]byte, error) {
+	return s.getBulkIngestJobResults(jobID, "failedResults")
+}
+
+// GetBulkIngestJobUnprocessedRecords gets the csv of records that were never
+// processed, e.g. because the job was aborted
+func (s *SalesforceUtils) GetBulkIngestJobUnprocessedRecords(jobID string) ([]byte, error) {
+	return s.getBulkIngestJobResults(jobID, "unprocessedrecords")
+}
+
+func (s *SalesforceUtils) getBulkIngestJobResults(jobID string, resultType string) ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(fmt.Sprintf("%s/%s/", s.getBulkIngestJobUrl(jobID), resultType))
+	req.Header.SetMethod(http.MethodGet)
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	// copy the body, the response is released when this method returns
+	return append([]byte(nil), body...), nil
+}
+
+// doBulkIngestJobRequest sends a request that returns an ingest job record
+func (s *SalesforceUtils) doBulkIngestJobRequest(req *fasthttp.Request, response *BulkJobRecord) error {
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	return json.Unmarshal(body, response)
+}
+
+func (s *SalesforceUtils) getBulkIngestUrl() string {
+	return fmt.Sprintf("%s/services/data/v%s/jobs/ingest", s.Config.BaseUrl, s.Config.ApiVersion)
+}
+
+func (s *SalesforceUtils) getBulkIngestJobUrl(jobID string) string {
+	return fmt.Sprintf("%s/%s", s.getBulkIngestUrl(), jobID)
+}
+
+func (s *SalesforceUtils) getBulkIngestJobBatchesUrl(jobID string) string {
+	return fmt.Sprintf("%s/batches", s.getBulkIngestJobUrl(jobID))
+}
@@ -0,0 +1,646 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// BulkCsvMarshaler is implemented by types that encode themselves as a bulk
+// api csv value. it takes precedence over encoding.TextMarshaler.
+type BulkCsvMarshaler interface {
+	MarshalBulkCSV() (string, error)
+}
+
+// BulkIngestOptions controls how BulkIngest and BulkIngestFromChannel run.
+// zero values are replaced with sensible defaults.
+type BulkIngestOptions struct {
+	// Operation is one of the BulkIngestOperation constants. defaults to
+	// insert.
+	Operation string
+	// ExternalIdFieldName is required for upserts
+	ExternalIdFieldName string
+	// KeyField is the column used to match result rows back to input items.
+	// defaults to Id for updates and deletes and to ExternalIdFieldName for
+	// upserts. inserts have no key by default and are matched on every
+	// column, so set it to a column that is unique per item, such as an
+	// external id, to match inserts reliably.
+	KeyField string
+	// Fields limits and orders the csv columns. struct items default to
+	// every exported field, map items default to the sorted keys of the
+	// first item.
+	Fields []string
+	// MaxJobBytes is the size of csv at which the input is split into
+	// another job. defaults to 100MB, which stays under the 150MB upload
+	// limit once salesforce base64 encodes the upload.
+	MaxJobBytes int
+	// PollInterval is how often jobs are polled for completion. defaults to
+	// 5 seconds.
+	PollInterval time.Duration
+}
+
+// BulkIngestItem is an input item with its position in the input
+type BulkIngestItem[T any] struct {
+	// Index is the position of the item in the input, or -1 if the result
+	// row couldn't be matched to an input item
+	Index int
+	Item  T
+	JobID string
+}
+
+// BulkIngestSuccess is an input item that was processed successfully
+type BulkIngestSuccess[T any] struct {
+	BulkIngestItem[T]
+	Id      string
+	Created bool
+}
+
+// BulkIngestFailure is an input item that failed to process
+type BulkIngestFailure[T any] struct {
+	BulkIngestItem[T]
+	Id    string
+	Error string
+}
+
+// BulkIngestSummary is the merged outcome of every job created for a
+// BulkIngest call. each list is sorted by input index.
+type BulkIngestSummary[T any] struct {
+	Jobs        []BulkJobRecord
+	Successful  []BulkIngestSuccess[T]
+	Failed      []BulkIngestFailure[T]
+	Unprocessed []BulkIngestItem[T]
+}
+
+// BulkIngest encodes items as csv and loads them with bulk 2.0 ingest jobs,
+// returning the results correlated back to the input items. items can be
+// structs, pointers to structs or map[string]interface{}.
+//
+// struct fields map to columns by their csv tag, then their json tag, then
+// their name. values are formatted using the field types from
+// DescribeObject, e.g. a time.Time is written as a date for a date field and
+// as a datetime for a datetime field. nil values are written as empty, which
+// leaves the field unchanged; implement BulkCsvMarshaler, or use
+// types.Nullable, to write "#N/A" and clear a field. string slices are
+// joined with ";" for multipicklists.
+//
+// the input is split into multiple jobs when the csv would exceed
+// options.MaxJobBytes. results are matched to input items by
+// options.KeyField, and items with the same key are matched in input order.
+// if a job fails to start, the jobs started before it are still collected and
+// their results are returned along with the error.
+func BulkIngest[T any](s *SalesforceUtils, objectType string, items []T, options BulkIngestOptions) (BulkIngestSummary[T], error) {
+	i := 0
+	return bulkIngest(s, objectType, func() (item T, ok bool) {
+		if i >= len(items) {
+			return
+		}
+		item, ok = items[i], true
+		i++
+		return
+	}, options)
+}
+
+// BulkIngestFromChannel is the same as BulkIngest but reads items from a
+// channel until it is closed. jobs are uploaded as soon as they are full, but
+// every item is kept in memory so results can be correlated to it. the
+// channel is read until it is closed even after an error, so the sender is
+// never left blocked.
+func BulkIngestFromChannel[T any](s *SalesforceUtils, objectType string, items <-chan T, options BulkIngestOptions) (BulkIngestSummary[T], error) {
+	return bulkIngest(s, objectType, func() (item T, ok bool) {
+		item, ok = <-items
+		return
+	}, options)
+}
+
+// bulkIngestJob is a single job's worth of encoded input
+type bulkIngestJob struct {
+	buffer  bytes.Buffer
+	record  BulkJobRecord
+	indexes map[string][]int
+}
+
+func bulkIngest[T any](s *SalesforceUtils, objectType string, next func() (T, bool), options BulkIngestOptions) (summary BulkIngestSummary[T], err error) {
+	if options.Operation == "" {
+		options.Operation = BulkIngestOperationInsert
+	}
+	if options.MaxJobBytes <= 0 {
+		options.MaxJobBytes = 100 * 1024 * 1024
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 5 * time.Second
+	}
+
+	// drain reads the rest of the input after a failure, so a producer
+	// sending on a channel isn't left blocked
+	drain := func() {
+		for _, ok := next(); ok; _, ok = next() {
+		}
+	}
+
+	first, ok := next()
+	if !ok {
+		return
+	}
+	describe, err := s.DescribeObject(objectType)
+	if err != nil {
+		drain()
+		err = errorx.Decorate(err, "failed to describe %s", objectType)
+		return
+	}
+	encoder, err := newBulkCsvEncoder(reflect.ValueOf(first), options.Fields, describe)
+	if err != nil {
+		drain()
+		return
+	}
+	header := encoder.header()
+	keyColumn, err := bulkIngestKeyColumn(header, options)
+	if err != nil {
+		drain()
+		return
+	}
+
+	// jobs only holds jobs that were started, so a failure part way through
+	// the input still collects the results of the jobs before it
+	var items []T
+	var jobs []*bulkIngestJob
+	var current *bulkIngestJob
+	var row bytes.Buffer
+	rowWriter := csv.NewWriter(&row)
+	for item, ok := first, true; ok; item, ok = next() {
+		record, encodeErr := encoder.record(reflect.ValueOf(item))
+		if encodeErr != nil {
+			err = errorx.Decorate(encodeErr, "failed to encode item %d", len(items))
+			break
+		}
+		row.Reset()
+		_ = rowWriter.Write(record)
+		rowWriter.Flush()
+
+		if current != nil && current.buffer.Len()+row.Len() > options.MaxJobBytes {
+			err = s.startBulkIngestJob(objectType, current, options)
+			if err != nil {
+				break
+			}
+			jobs = append(jobs, current)
+			current = nil
+		}
+		if current == nil {
+			current = &bulkIngestJob{indexes: map[string][]int{}}
+			headerWriter := csv.NewWriter(&current.buffer)
+			_ = headerWriter.Write(header)
+			headerWriter.Flush()
+		}
+		current.buffer.Write(row.Bytes())
+		key := bulkIngestRowKey(header, record, keyColumn)
+		current.indexes[key] = append(current.indexes[key], len(items))
+		items = append(items, item)
+	}
+	if err == nil {
+		err = s.startBulkIngestJob(objectType, current, options)
+		if err == nil {
+			jobs = append(jobs, current)
+		}
+	}
+	if err != nil {
+		drain()
+	}
+
+	var jobErrors []string
+	for _, job := range jobs {
+		jobErr := collectBulkIngestResults(s, job, header, keyColumn, items, &summary, options)
+		summary.Jobs = append(summary.Jobs, job.record)
+		if jobErr != nil {
+			jobErrors = append(jobErrors, fmt.Sprintf("job %s: %s", job.record.ID, jobErr))
+		}
+	}
+	sort.SliceStable(summary.Successful, func(i, j int) bool {
+		return summary.Successful[i].Index < summary.Successful[j].Index
+	})
+	sort.SliceStable(summary.Failed, func(i, j int) bool {
+		return summary.Failed[i].Index < summary.Failed[j].Index
+	})
+	sort.SliceStable(summary.Unprocessed, func(i, j int) bool {
+		return summary.Unprocessed[i].Index < summary.Unprocessed[j].Index
+	})
+	if len(jobErrors) > 0 {
+		jobsErr := errorx.IllegalState.New("%d of %d jobs failed: %s", len(jobErrors), len(jobs), strings.Join(jobErrors, "; "))
+		err = errorx.DecorateMany("bulk ingest failed", err, jobsErr)
+	}
+	return
+}
+
+// startBulkIngestJob creates a job for the encoded input, uploads it and
+// closes the job so salesforce starts processing it
+func (s *SalesforceUtils) startBulkIngestJob(objectType string, job *bulkIngestJob, options BulkIngestOptions) error {
+	record, err := s.CreateBulkIngestJob(BulkIngestJobRequest{
+		Object:              objectType,
+		Operation:           options.Operation,
+		ExternalIdFieldName: options.ExternalIdFieldName,
+	})
+	if err != nil {
+		return errorx.Decorate(err, "failed to create ingest job")
+	}
+	job.record = record
+	err = s.UploadBulkIngestJobData(record.ID, job.buffer.Bytes())
+	if err != nil {
+		_, _ = s.AbortBulkIngestJob(record.ID)
+		return errorx.Decorate(err, "failed to upload data for ingest job %s", record.ID)
+	}
+	// the upload is no longer needed, only the indexes are used from here
+	job.buffer = bytes.Buffer{}
+	job.record, err = s.CloseBulkIngestJob(record.ID)
+	if err != nil {
+		_, _ = s.AbortBulkIngestJob(record.ID)
+		return errorx.Decorate(err, "failed to close ingest job %s", record.ID)
+	}
+	return nil
+}
+
+// collectBulkIngestResults waits for the job to finish and adds its results
+// to the summary. results are collected even if the job failed, so that
+// records processed before the failure are reported.
+func collectBulkIngestResults[T any](s *SalesforceUtils, job *bulkIngestJob, header []string, keyColumn int, items []T, summary *BulkIngestSummary[T], options BulkIngestOptions) error {
+	record, waitErr := s.WaitForBulkIngestJob(job.record.ID, options.PollInterval)
+	if record.ID != "" {
+		job.record = record
+	}
+
+	// match finds the input item for a result row by its key, falling back
+	// to sf__Id when an Id key column isn't echoed back
+	match := func(resultHeader []string, row []string) BulkIngestItem[T] {
+		values := map[string]string{}
+		for i, column := range resultHeader {
+			if i < len(row) {
+				values[strings.ToLower(column)] = row[i]
+			}
+		}
+		uploaded := make([]string, len(header))
+		for i, column := range header {
+			uploaded[i] = values[strings.ToLower(column)]
+		}
+		if keyColumn >= 0 && uploaded[keyColumn] == "" && strings.EqualFold(header[keyColumn], "Id") {
+			uploaded[keyColumn] = values["sf__id"]
+		}
+		key := bulkIngestRowKey(header, uploaded, keyColumn)
+		matched := BulkIngestItem[T]{Index: -1, JobID: job.record.ID}
+		indexes := job.indexes[key]
+		if len(indexes) > 0 {
+			matched.Index = indexes[0]
+			matched.Item = items[indexes[0]]
+			job.indexes[key] = indexes[1:]
+		}
+		return matched
+	}
+	column := func(resultHeader []string, row []string, name string) string {
+		for i, headerName := range resultHeader {
+			if headerName == name && i < len(row) {
+				return row[i]
+			}
+		}
+		return ""
+	}
+
+	body, err := s.GetBulkIngestJobSuccessfulResults(job.record.ID)
+	if err != nil {
+		return err
+	}
+	resultHeader, rows, err := readBulkResultCsv(body)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		summary.Successful = append(summary.Successful, BulkIngestSuccess[T]{
+			BulkIngestItem: match(resultHeader, row),
+			Id:             column(resultHeader, row, "sf__Id"),
+			Created:        column(resultHeader, row, "sf__Created") == "true",
+		})
+	}
+
+	body, err = s.GetBulkIngestJobFailedResults(job.record.ID)
+	if err != nil {
+		return err
+	}
+	resultHeader, rows, err = readBulkResultCsv(body)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		summary.Failed = append(summary.Failed, BulkIngestFailure[T]{
+			BulkIngestItem: match(resultHeader, row),
+			Id:             column(resultHeader, row, "sf__Id"),
+			Error:          column(resultHeader, row, "sf__Error"),
+		})
+	}
+
+	body, err = s.GetBulkIngestJobUnprocessedRecords(job.record.ID)
+	if err != nil {
+		return err
+	}
+	resultHeader, rows, err = readBulkResultCsv(body)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		summary.Unprocessed = append(summary.Unprocessed, match(resultHeader, row))
+	}
+	return waitErr
+}
+
+// bulkIngestKeyColumn finds the column used to match result rows to input
+// rows, or -1 to match on every column
+func bulkIngestKeyColumn(header []string, options BulkIngestOptions) (int, error) {
+	keyField := options.KeyField
+	if keyField == "" {
+		switch options.Operation {
+		case BulkIngestOperationUpdate, BulkIngestOperationDelete, BulkIngestOperationHardDelete:
+			keyField = "Id"
+		case BulkIngestOperationUpsert:
+			keyField = options.ExternalIdFieldName
+		}
+	}
+	if keyField == "" {
+		return -1, nil
+	}
+	for i, column := range header {
+		if strings.EqualFold(column, keyField) {
+			return i, nil
+		}
+	}
+	if options.KeyField != "" {
+		return -1, errorx.IllegalArgument.New("key field %s is not one of the columns", options.KeyField)
+	}
+	return -1, nil
+}
+
+// bulkIngestRowKey is the key used to match a result row to an input row.
+// a key column is trimmed, and reduced to 15 characters for ids, since
+// salesforce may echo it back normalised. without a key column every value
+// is compared.
+func bulkIngestRowKey(header []string, values []string, keyColumn int) string {
+	if keyColumn < 0 {
+		return strings.Join(values, "\x1f")
+	}
+	key := strings.TrimSpace(values[keyColumn])
+	if strings.EqualFold(header[keyColumn], "Id") {
+		key = truncateId(key)
+	}
+	return key
+}
+
+// bulkCsvColumn is a single column of the csv, along with where its value
+// comes from
+type bulkCsvColumn struct {
+	name string
+	// fieldIndex is the index path of the struct field, nil for map items
+	fieldIndex []int
+	// fieldType is the salesforce field type from describe
+	fieldType string
+}
+
+// bulkCsvEncoder encodes struct or map items as csv records
+type bulkCsvEncoder struct {
+	columns []bulkCsvColumn
+	isMap   bool
+}
+
+func newBulkCsvEncoder(sample reflect.Value, fields []string, describe DescribeObjectResponse) (*bulkCsvEncoder, error) {
+	fieldTypes := map[string]string{}
+	for _, field := range describe.Fields {
+		fieldTypes[strings.ToLower(field.Name)] = field.Type
+	}
+	encoder := &bulkCsvEncoder{}
+	var err error
+
+	for sample.Kind() == reflect.Ptr || sample.Kind() == reflect.Interface {
+		if sample.IsNil() {
+			return nil, errorx.IllegalArgument.New("first item must not be nil")
+		}
+		sample = sample.Elem()
+	}
+	switch sample.Kind() {
+	case reflect.Struct:
+		for _, field := range reflect.VisibleFields(sample.Type()) {
+			if !field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+				continue
+			}
+			name := bulkCsvFieldName(field)
+			if name == "" {
+				continue
+			}
+			encoder.columns = append(encoder.columns, bulkCsvColumn{name: name, fieldIndex: field.Index})
+		}
+	case reflect.Map:
+		if sample.Type().Key().Kind() != reflect.String {
+			return nil, errorx.IllegalArgument.New("map items must have string keys")
+		}
+		encoder.isMap = true
+		if len(fields) == 0 {
+			for _, key := range sample.MapKeys() {
+				fields = append(fields, key.String())
+			}
+			sort.Strings(fields)
+		}
+	default:
+		return nil, errorx.IllegalArgument.New("items must be structs or maps, got %s", sample.Type())
+	}
+
+	if len(fields) > 0 {
+		encoder.columns, err = encoder.selectColumns(fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, column := range encoder.columns {
+		// relationship columns such as Account.External_Id__c aren't
+		// fields of the object itself
+		if strings.Contains(column.name, ".") {
+			continue
+		}
+		fieldType, ok := fieldTypes[strings.ToLower(column.name)]
+		if !ok {
+			return nil, errorx.IllegalArgument.New("%s is not a field of %s", column.name, describe.Name)
+		}
+		encoder.columns[i].fieldType = fieldType
+	}
+	if len(encoder.columns) == 0 {
+		return nil, errorx.IllegalArgument.New("items have no fields to encode")
+	}
+	return encoder, nil
+}
+
+// selectColumns limits and orders the columns to fields. for map items every
+// field becomes a column.
+func (e *bulkCsvEncoder) selectColumns(fields []string) ([]bulkCsvColumn, error) {
+	byName := map[string]bulkCsvColumn{}
+	for _, column := range e.columns {
+		byName[column.name] = column
+	}
+	columns := make([]bulkCsvColumn, 0, len(fields))
+	for _, field := range fields {
+		column, ok := byName[field]
+		if !ok && !e.isMap {
+			return nil, errorx.IllegalArgument.New("items have no field for column %s", field)
+		}
+		column.name = field
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// bulkCsvFieldName gets the column name for a struct field from its csv or
+// json tag, falling back to the field name. an empty name means the field is
+// skipped.
+func bulkCsvFieldName(field reflect.StructField) string {
+	for _, tagName := range []string{"csv", "json"} {
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func (e *bulkCsvEncoder) header() []string {
+	header := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = column.name
+	}
+	return header
+}
+
+// record encodes a single item as a csv record
+func (e *bulkCsvEncoder) record(item reflect.Value) ([]string, error) {
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		if item.IsNil() {
+			return nil, errorx.IllegalArgument.New("item must not be nil")
+		}
+		item = item.Elem()
+	}
+	record := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		var value reflect.Value
+		if e.isMap {
+			if item.Kind() != reflect.Map {
+				return nil, errorx.IllegalArgument.New("expected a map, got %s", item.Type())
+			}
+			value = item.MapIndex(reflect.ValueOf(column.name).Convert(item.Type().Key()))
+		} else {
+			if item.Kind() != reflect.Struct {
+				return nil, errorx.IllegalArgument.New("expected a struct, got %s", item.Type())
+			}
+			// a nil embedded pointer leaves its fields empty
+			value, _ = item.FieldByIndexErr(column.fieldIndex)
+		}
+		formatted, err := formatBulkCsvValue(value, column.fieldType)
+		if err != nil {
+			return nil, errorx.Decorate(err, "failed to encode %s", column.name)
+		}
+		record[i] = formatted
+	}
+	return record, nil
+}
+
+// formatBulkCsvValue formats a single value for the bulk api, using the
+// salesforce field type to decide how times are written
+func formatBulkCsvValue(value reflect.Value, fieldType string) (string, error) {
+	for {
+		if !value.IsValid() {
+			return "", nil
+		}
+		if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+			return "", nil
+		}
+		if value.CanInterface() {
+			switch v := value.Interface().(type) {
+			case BulkCsvMarshaler:
+				return v.MarshalBulkCSV()
+			case time.Time:
+				return formatBulkCsvTime(v, fieldType), nil
+			case *time.Time:
+				// checked before TextMarshaler, which *time.Time also
+				// implements, so nillable dates get the field's format
+				return formatBulkCsvTime(*v, fieldType), nil
+			case encoding.TextMarshaler:
+				text, err := v.MarshalText()
+				return string(text), err
+			}
+		}
+		if value.Kind() != reflect.Ptr && value.Kind() != reflect.Interface {
+			break
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		// multipicklist values are separated by semicolons
+		values := make([]string, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			formatted, err := formatBulkCsvValue(value.Index(i), fieldType)
+			if err != nil {
+				return "", err
+			}
+			values[i] = formatted
+		}
+		return strings.Join(values, ";"), nil
+	}
+	return fmt.Sprint(value.Interface()), nil
+}
+
+// formatBulkCsvTime formats a time for a date, time or datetime field
+func formatBulkCsvTime(t time.Time, fieldType string) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch fieldType {
+	case "date":
+		return t.Format("2006-01-02")
+	case "time":
+		return t.UTC().Format("15:04:05.000Z")
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// readBulkResultCsv reads a bulk results csv, returning its header and rows
+func readBulkResultCsv(body []byte) ([]string, [][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errorx.Decorate(err, "failed to read results header")
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, errorx.Decorate(err, "failed to read results")
+	}
+	return header, rows, nil
+}
@@ -0,0 +1,358 @@
+package pkg
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// BulkV1ContentType is the format of the data sent to and returned by a bulk
+// 1.0 job
+type BulkV1ContentType string
+
+const (
+	BulkV1ContentTypeCSV  BulkV1ContentType = "CSV"
+	BulkV1ContentTypeJSON BulkV1ContentType = "JSON"
+)
+
+// BulkV1JobRequest is the body used to create a bulk 1.0 job
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_asynch.meta/api_asynch/asynch_api_reference_jobinfo.htm
+type BulkV1JobRequest struct {
+	// Operation is one of insert, update, upsert, delete, hardDelete, query
+	// or queryAll
+	Operation string `json:"operation"`
+	// Object is the salesforce object type, e.g. "Account"
+	Object string `json:"object"`
+	// ContentType is the format of the batches, defaults to CSV
+	ContentType BulkV1ContentType `json:"contentType,omitempty"`
+	// ConcurrencyMode is either Parallel (the default) or Serial
+	ConcurrencyMode string `json:"concurrencyMode,omitempty"`
+	// ExternalIdFieldName is required for upserts
+	ExternalIdFieldName string `json:"externalIdFieldName,omitempty"`
+}
+
+// BulkV1JobOptions are optional settings sent as headers when creating a bulk
+// 1.0 job
+type BulkV1JobOptions struct {
+	// PKChunking enables pk chunking for query jobs. salesforce splits the
+	// query into one batch per chunk.
+	PKChunking bool
+	// PKChunkingChunkSize is the number of records per chunk, salesforce
+	// defaults to 100,000 when not set
+	PKChunkingChunkSize int
+	// PKChunkingParent is the parent object when querying a sharing object,
+	// e.g. "Account" for AccountShare
+	PKChunkingParent string
+	// PKChunkingStartRow is the 15 or 18 character Id of the first record
+	// to chunk from
+	PKChunkingStartRow string
+}
+
+// BulkV1JobInfo is the job info returned by the bulk 1.0 api
+type BulkV1JobInfo struct {
+	Id                      string            `json:"id" xml:"id"`
+	Operation               string            `json:"operation" xml:"operation"`
+	Object                  string            `json:"object" xml:"object"`
+	CreatedById             string            `json:"createdById" xml:"createdById"`
+	CreatedDate             string            `json:"createdDate" xml:"createdDate"`
+	SystemModstamp          string            `json:"systemModstamp" xml:"systemModstamp"`
+	State                   string            `json:"state" xml:"state"`
+	ExternalIdFieldName     string            `json:"externalIdFieldName" xml:"externalIdFieldName"`
+	ConcurrencyMode         string            `json:"concurrencyMode" xml:"concurrencyMode"`
+	ContentType             BulkV1ContentType `json:"contentType" xml:"contentType"`
+	NumberBatchesQueued     int               `json:"numberBatchesQueued" xml:"numberBatchesQueued"`
+	NumberBatchesInProgress int               `json:"numberBatchesInProgress" xml:"numberBatchesInProgress"`
+	NumberBatchesCompleted  int               `json:"numberBatchesCompleted" xml:"numberBatchesCompleted"`
+	NumberBatchesFailed     int               `json:"numberBatchesFailed" xml:"numberBatchesFailed"`
+	NumberBatchesTotal      int               `json:"numberBatchesTotal" xml:"numberBatchesTotal"`
+	NumberRecordsProcessed  int64             `json:"numberRecordsProcessed" xml:"numberRecordsProcessed"`
+	NumberRecordsFailed     int64             `json:"numberRecordsFailed" xml:"numberRecordsFailed"`
+	NumberRetries           int64             `json:"numberRetries" xml:"numberRetries"`
+	ApiVersion              float64           `json:"apiVersion" xml:"apiVersion"`
+	TotalProcessingTime     int64             `json:"totalProcessingTime" xml:"totalProcessingTime"`
+	ApiActiveProcessingTime int64             `json:"apiActiveProcessingTime" xml:"apiActiveProcessingTime"`
+	ApexProcessingTime      int64             `json:"apexProcessingTime" xml:"apexProcessingTime"`
+}
+
+// states a bulk 1.0 batch can be in, as reported in BulkV1BatchInfo.State
+const (
+	BulkV1BatchStateQueued       = "Queued"
+	BulkV1BatchStateInProgress   = "InProgress"
+	BulkV1BatchStateCompleted    = "Completed"
+	BulkV1BatchStateFailed       = "Failed"
+	BulkV1BatchStateNotProcessed = "NotProcessed"
+)
+
+// BulkV1BatchInfo is the batch info returned by the bulk 1.0 api
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_asynch.meta/api_asynch/asynch_api_reference_batchinfo.htm
+type BulkV1BatchInfo struct {
+	Id                      string `json:"id" xml:"id"`
+	JobId                   string `json:"jobId" xml:"jobId"`
+	State                   string `json:"state" xml:"state"`
+	StateMessage            string `json:"stateMessage" xml:"stateMessage"`
+	CreatedDate             string `json:"createdDate" xml:"createdDate"`
+	SystemModstamp          string `json:"systemModstamp" xml:"systemModstamp"`
+	NumberRecordsProcessed  int64  `json:"numberRecordsProcessed" xml:"numberRecordsProcessed"`
+	NumberRecordsFailed     int64  `json:"numberRecordsFailed" xml:"numberRecordsFailed"`
+	TotalProcessingTime     int64  `json:"totalProcessingTime" xml:"totalProcessingTime"`
+	ApiActiveProcessingTime int64  `json:"apiActiveProcessingTime" xml:"apiActiveProcessingTime"`
+	ApexProcessingTime      int64  `json:"apexProcessingTime" xml:"apexProcessingTime"`
+}
+
+// bulkV1BatchInfoList is the response when listing the batches of a job. the
+// same field name is used by the json and xml representations.
+type bulkV1BatchInfoList struct {
+	BatchInfo []BulkV1BatchInfo `json:"batchInfo" xml:"batchInfo"`
+}
+
+// bulkV1ResultList is the xml representation of the result ids of a query
+// batch
+type bulkV1ResultList struct {
+	Result []string `xml:"result"`
+}
+
+// CreateBulkV1Job creates a bulk 1.0 job. batches can be added until the job
+// is closed with CloseBulkV1Job.
+func (s *SalesforceUtils) CreateBulkV1Job(job BulkV1JobRequest, options BulkV1JobOptions) (response BulkV1JobInfo, err error) {
+	if job.ContentType == "" {
+		job.ContentType = BulkV1ContentTypeCSV
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		err = errorx.Decorate(err, "failed to marshal job")
+		return
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1JobUrl())
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if options.PKChunking {
+		req.Header.Set("Sforce-Enable-PKChunking", options.pkChunkingHeader())
+	}
+	req.SetBody(body)
+	err = s.doBulkV1Request(req, http.StatusCreated, &response)
+	return
+}
+
+// pkChunkingHeader builds the value of the Sforce-Enable-PKChunking header
+func (o BulkV1JobOptions) pkChunkingHeader() string {
+	var params []string
+	if o.PKChunkingChunkSize > 0 {
+		params = append(params, fmt.Sprintf("chunkSize=%d", o.PKChunkingChunkSize))
+	}
+	if o.PKChunkingParent != "" {
+		params = append(params, fmt.Sprintf("parent=%s", o.PKChunkingParent))
+	}
+	if o.PKChunkingStartRow != "" {
+		params = append(params, fmt.Sprintf("startRow=%s", o.PKChunkingStartRow))
+	}
+	if len(params) == 0 {
+		return "true"
+	}
+	return strings.Join(params, "; ")
+}
+
+// GetBulkV1Job gets the current job info for a bulk 1.0 job
+func (s *SalesforceUtils) GetBulkV1Job(jobID string) (response BulkV1JobInfo, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1JobIdUrl(jobID))
+	req.Header.SetMethod(http.MethodGet)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	err = s.doBulkV1Request(req, http.StatusOK, &response)
+	return
+}
+
+// CloseBulkV1Job closes a bulk 1.0 job, telling salesforce that no more
+// batches will be added
+func (s *SalesforceUtils) CloseBulkV1Job(jobID string) (BulkV1JobInfo, error) {
+	return s.setBulkV1JobState(jobID, "Closed")
+}
+
+// AbortBulkV1Job aborts a bulk 1.0 job. batches that have already been
+// processed are not rolled back.
+func (s *SalesforceUtils) AbortBulkV1Job(jobID string) (BulkV1JobInfo, error) {
+	return s.setBulkV1JobState(jobID, "Aborted")
+}
+
+func (s *SalesforceUtils) setBulkV1JobState(jobID string, state string) (response BulkV1JobInfo, err error) {
+	body, err := json.Marshal(map[string]string{"state": state})
+	if err != nil {
+		err = errorx.Decorate(err, "failed to marshal job state")
+		return
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1JobIdUrl(jobID))
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.SetBody(body)
+	err = s.doBulkV1Request(req, http.StatusOK, &response)
+	return
+}
+
+// AddBulkV1BatchCSV adds a batch of csv records to a bulk 1.0 job created with
+// the CSV content type. for query jobs the body is the SOQL query.
+func (s *SalesforceUtils) AddBulkV1BatchCSV(jobID string, body []byte) (BulkV1BatchInfo, error) {
+	return s.addBulkV1Batch(jobID, "text/csv; charset=UTF-8", body)
+}
+
+// AddBulkV1BatchJSON adds a batch of json records to a bulk 1.0 job created
+// with the JSON content type. for query jobs the body is the SOQL query.
+func (s *SalesforceUtils) AddBulkV1BatchJSON(jobID string, body []byte) (BulkV1BatchInfo, error) {
+	return s.addBulkV1Batch(jobID, "application/json; charset=UTF-8", body)
+}
+
+func (s *SalesforceUtils) addBulkV1Batch(jobID string, contentType string, body []byte) (response BulkV1BatchInfo, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1BatchUrl(jobID))
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", contentType)
+	req.SetBody(body)
+	err = s.doBulkV1Request(req, http.StatusCreated, &response)
+	return
+}
+
+// GetBulkV1Batch gets the current batch info for a single batch
+func (s *SalesforceUtils) GetBulkV1Batch(jobID string, batchID string) (response BulkV1BatchInfo, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1BatchIdUrl(jobID, batchID))
+	req.Header.SetMethod(http.MethodGet)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	err = s.doBulkV1Request(req, http.StatusOK, &response)
+	return
+}
+
+// ListBulkV1Batches gets the batch info for every batch in a job. when pk
+// chunking is enabled this includes the batches salesforce created for each
+// chunk.
+func (s *SalesforceUtils) ListBulkV1Batches(jobID string) ([]BulkV1BatchInfo, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getBulkV1BatchUrl(jobID))
+	req.Header.SetMethod(http.MethodGet)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	var response bulkV1BatchInfoList
+	err := s.doBulkV1Request(req, http.StatusOK, &response)
+	return response.BatchInfo, err
+}
+
+// GetBulkV1BatchResult gets the raw result of a batch. for ingest jobs this is
+// the per-record results in the job's content type. for query jobs use
+// GetBulkV1BatchResultList and GetBulkV1QueryResult instead.
+func (s *SalesforceUtils) GetBulkV1BatchResult(jobID string, batchID string) ([]byte, error) {
+	body, _, err := s.getBulkV1Raw(s.getBulkV1BatchResultUrl(jobID, batchID))
+	return body, err
+}
+
+// GetBulkV1BatchResultList gets the ids of the result sets of a query batch
+func (s *SalesforceUtils) GetBulkV1BatchResultList(jobID string, batchID string) ([]string, error) {
+	body, contentType, err := s.getBulkV1Raw(s.getBulkV1BatchResultUrl(jobID, batchID))
+	if err != nil {
+		return nil, err
+	}
+	if isXmlContentType(contentType) {
+		var resultList bulkV1ResultList
+		err = xml.Unmarshal(body, &resultList)
+		return resultList.Result, err
+	}
+	var resultIds []string
+	err = json.Unmarshal(body, &resultIds)
+	return resultIds, err
+}
+
+// GetBulkV1QueryResult gets a single result set of a query batch
+func (s *SalesforceUtils) GetBulkV1QueryResult(jobID string, batchID string, resultID string) ([]byte, error) {
+	body, _, err := s.getBulkV1Raw(fmt.Sprintf("%s/%s", s.getBulkV1BatchResultUrl(jobID, batchID), resultID))
+	return body, err
+}
+
+// getBulkV1Raw makes a GET request to the bulk 1.0 api, returning a copy of
+// the body and the response content type
+func (s *SalesforceUtils) getBulkV1Raw(uri string) ([]byte, string, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(uri)
+	req.Header.SetMethod(http.MethodGet)
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(res)
+	err := s.sendBulkV1Request(req, res)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.StatusCode() != http.StatusOK {
+		return nil, "", errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+	}
+	return append([]byte(nil), res.Body()...), string(res.Header.ContentType()), nil
+}
+
+// doBulkV1Request sends a request to the bulk 1.0 api and decodes the
+// response into response. the bulk 1.0 api answers in xml for csv jobs, so
+// the response is decoded based on its content type.
+func (s *SalesforceUtils) doBulkV1Request(req *fasthttp.Request, expectedStatusCode int, response interface{}) error {
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(res)
+	err := s.sendBulkV1Request(req, res)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() != expectedStatusCode {
+		return errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+	}
+	if isXmlContentType(string(res.Header.ContentType())) {
+		err = xml.Unmarshal(res.Body(), response)
+	} else {
+		err = json.Unmarshal(res.Body(), response)
+	}
+	if err != nil {
+		return errorx.Decorate(err, "failed to unmarshal response with body: %s", res.Body())
+	}
+	return nil
+}
+
+// sendBulkV1Request sends a request to the bulk 1.0 api. bulk 1.0 doesn't
+// accept a bearer token, the session id is sent in the X-SFDC-Session header
+// instead.
+func (s *SalesforceUtils) sendBulkV1Request(req *fasthttp.Request, res *fasthttp.Response) error {
+	req.Header.Set("X-SFDC-Session", s.Credentials.AccessToken)
+	return s.FastHTTPClient.Do(req, res)
+}
+
+func isXmlContentType(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "xml")
+}
+
+// getBulkV1Url gets a formatted url to the bulk 1.0 api
+func (s *SalesforceUtils) getBulkV1Url() string {
+	return fmt.Sprintf("%s/services/async/%s", s.Config.BaseUrl, s.Config.ApiVersion)
+}
+
+func (s *SalesforceUtils) getBulkV1JobUrl() string {
+	return fmt.Sprintf("%s/job", s.getBulkV1Url())
+}
+
+func (s *SalesforceUtils) getBulkV1JobIdUrl(jobID string) string {
+	return fmt.Sprintf("%s/%s", s.getBulkV1JobUrl(), jobID)
+}
+
+func (s *SalesforceUtils) getBulkV1BatchUrl(jobID string) string {
+	return fmt.Sprintf("%s/batch", s.getBulkV1JobIdUrl(jobID))
+}
+
+func (s *SalesforceUtils) getBulkV1BatchIdUrl(jobID string, batchID string) string {
+	return fmt.Sprintf("%s/%s", s.getBulkV1BatchUrl(jobID), batchID)
+}
+
+func (s *SalesforceUtils) getBulkV1BatchResultUrl(jobID string, batchID string) string {
+	return fmt.Sprintf("%s/result", s.getBulkV1BatchIdUrl(jobID, batchID))
+}
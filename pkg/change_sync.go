@@ -0,0 +1,382 @@
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/catalystcommunity/salesforce-utils/pkg/types"
+	"github.com/joomcode/errorx"
+)
+
+// soqlDateTimeLayout is the layout of datetime literals in SOQL, which don't
+// take fractional seconds
+const soqlDateTimeLayout = "2006-01-02T15:04:05Z"
+
+// SyncWatermark is the position of a change sync: the highest
+// (SystemModstamp, Id) of the records handed to the sink. the Id breaks ties
+// between records modified at the same time.
+type SyncWatermark struct {
+	SystemModstamp time.Time `json:"systemModstamp"`
+	Id             string    `json:"id"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// SyncStateStore persists the watermark of each synced object type.
+// implementations must return a nil watermark and a nil error from
+// LoadWatermark when the type has never been synced.
+type SyncStateStore interface {
+	LoadWatermark(typeName string) (*SyncWatermark, error)
+	SaveWatermark(typeName string, watermark SyncWatermark) error
+}
+
+// FileSyncStateStore is a SyncStateStore that keeps one json file per object
+// type in a directory
+type FileSyncStateStore struct {
+	Dir string
+}
+
+// NewFileSyncStateStore creates a FileSyncStateStore, creating the directory
+// if it doesn't exist
+func NewFileSyncStateStore(dir string) (*FileSyncStateStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to create sync state directory")
+	}
+	return &FileSyncStateStore{Dir: dir}, nil
+}
+
+func (f *FileSyncStateStore) LoadWatermark(typeName string) (*SyncWatermark, error) {
+	return loadJsonFile[SyncWatermark](f.path(typeName))
+}
+
+func (f *FileSyncStateStore) SaveWatermark(typeName string, watermark SyncWatermark) error {
+	return saveJsonFile(f.path(typeName), watermark)
+}
+
+func (f *FileSyncStateStore) path(typeName string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s.watermark.json", url.PathEscape(typeName)))
+}
+
+// ChangeType is the kind of change a ChangeEvent reports
+type ChangeType string
+
+const (
+	// ChangeTypeUpsert is a record that was created or updated
+	ChangeTypeUpsert ChangeType = "Upsert"
+	// ChangeTypeDelete is a record that was deleted, it's still readable
+	// through queryAll until it's purged from the recycle bin
+	ChangeTypeDelete ChangeType = "Delete"
+)
+
+// ChangeEvent is a single changed record
+type ChangeEvent struct {
+	ObjectType     string
+	Type           ChangeType
+	Id             string
+	SystemModstamp time.Time
+	// Record holds the selected fields. records read through the rest api
+	// keep their json types, records read through a bulk job have string
+	// values.
+	Record map[string]interface{}
+}
+
+// ChangeSink receives the changes of a sync one batch at a time, each batch
+// in (SystemModstamp, Id) order. batches read through the rest api are also
+// in order with each other, batches of a bulk job are not. changes are handed
+// over again when the sync is interrupted before the watermark covering them
+// is saved, so handlers must be idempotent.
+type ChangeSink interface {
+	HandleChanges(events []ChangeEvent) error
+}
+
+// ChangeSinkFunc adapts a function to a ChangeSink
+type ChangeSinkFunc func(events []ChangeEvent) error
+
+func (f ChangeSinkFunc) HandleChanges(events []ChangeEvent) error {
+	return f(events)
+}
+
+// ChangeSyncOptions controls how SyncChanges runs. zero values are replaced
+// with sensible defaults.
+type ChangeSyncOptions struct {
+	// Fields are the fields to select. Id, SystemModstamp and IsDeleted are
+	// always selected.
+	Fields []string
+	// Where is an optional SOQL condition the records must also match
+	Where string
+	// BulkThreshold is the number of changed records above which a bulk
+	// query job is used instead of the rest api. defaults to 10,000, set it
+	// to a negative value to always use the rest api. a bulk sync only saves
+	// its watermark once the whole job has been read, so one that fails part
+	// way starts over, while a rest sync resumes after its last page.
+	BulkThreshold int
+	// PollInterval is how often a bulk query job is polled for completion.
+	// defaults to 5 seconds.
+	PollInterval time.Duration
+	// Since is where a type that has never been synced starts from. the zero
+	// value syncs every record.
+	Since time.Time
+}
+
+func (o ChangeSyncOptions) withDefaults() ChangeSyncOptions {
+	if o.BulkThreshold == 0 {
+		o.BulkThreshold = 10000
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = 5 * time.Second
+	}
+	return o
+}
+
+// ChangeSyncResult summarizes a SyncChanges run
+type ChangeSyncResult struct {
+	ObjectType string
+	Upserted   int
+	Deleted    int
+	// UsedBulk is true when the changes were read through a bulk query job
+	UsedBulk  bool
+	Watermark SyncWatermark
+}
+
+// SyncChanges reads every record of typeName modified after the stored
+// watermark, including deleted records, and hands them to sink. a finished
+// sync picks up only newer changes on the next run.
+//
+// the changes are paged through the rest api, or read with a bulk query job
+// when there are more than options.BulkThreshold of them. rest pages come
+// back in (SystemModstamp, Id) order, so the watermark is saved after every
+// page the sink accepts and an interrupted sync resumes after it. bulk jobs
+// don't guarantee the order of their results, so the watermark is only saved
+// once every page of the job has been accepted: a bulk sync is all or
+// nothing, and one that fails part way hands every change over again on the
+// next run. set options.BulkThreshold negative to always page through the
+// rest api instead.
+func (s *SalesforceUtils) SyncChanges(typeName string, store SyncStateStore, sink ChangeSink, options ChangeSyncOptions) (result ChangeSyncResult, err error) {
+	options = options.withDefaults()
+	result.ObjectType = typeName
+	watermark, err := store.LoadWatermark(typeName)
+	if err != nil {
+		return result, errorx.Decorate(err, "failed to load watermark for %s", typeName)
+	}
+	if watermark == nil {
+		watermark = &SyncWatermark{SystemModstamp: options.Since}
+	}
+	result.Watermark = *watermark
+
+	where := changeSyncCondition(*watermark, options.Where)
+	useBulk := false
+	if options.BulkThreshold > 0 {
+		count, err := s.ExecuteSoqlQueryAll(fmt.Sprintf("SELECT COUNT() FROM %s%s", typeName, where))
+		if err != nil {
+			return result, errorx.Decorate(err, "failed to count changes of %s", typeName)
+		}
+		useBulk = count.TotalSize > options.BulkThreshold
+	}
+	soql := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY SystemModstamp ASC, Id ASC", strings.Join(changeSyncFields(options.Fields), ", "), typeName, where)
+
+	// handle hands a batch to the sink and tracks the highest watermark seen,
+	// which save then stores
+	next := *watermark
+	handle := func(events []ChangeEvent) error {
+		if len(events) == 0 {
+			return nil
+		}
+		sortChangeEvents(events)
+		err := sink.HandleChanges(events)
+		if err != nil {
+			return errorx.Decorate(err, "sink failed to handle changes of %s", typeName)
+		}
+		for _, event := range events {
+			if event.SystemModstamp.After(next.SystemModstamp) || (event.SystemModstamp.Equal(next.SystemModstamp) && event.Id > next.Id) {
+				next.SystemModstamp, next.Id = event.SystemModstamp, event.Id
+			}
+			if event.Type == ChangeTypeDelete {
+				result.Deleted++
+			} else {
+				result.Upserted++
+			}
+		}
+		return nil
+	}
+	save := func() error {
+		if next.SystemModstamp.Equal(result.Watermark.SystemModstamp) && next.Id == result.Watermark.Id {
+			return nil
+		}
+		next.UpdatedAt = time.Now()
+		err := store.SaveWatermark(typeName, next)
+		if err != nil {
+			return errorx.Decorate(err, "failed to save watermark for %s", typeName)
+		}
+		result.Watermark = next
+		return nil
+	}
+
+	if useBulk {
+		result.UsedBulk = true
+		err = s.syncChangesWithBulk(typeName, soql, options.PollInterval, handle)
+		if err == nil {
+			err = save()
+		}
+	} else {
+		err = s.syncChangesWithRest(typeName, soql, func(events []ChangeEvent) error {
+			err := handle(events)
+			if err != nil {
+				return err
+			}
+			return save()
+		})
+	}
+	return result, err
+}
+
+// sortChangeEvents sorts events in (SystemModstamp, Id) order
+func sortChangeEvents(events []ChangeEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].SystemModstamp.Equal(events[j].SystemModstamp) {
+			return events[i].SystemModstamp.Before(events[j].SystemModstamp)
+		}
+		return events[i].Id < events[j].Id
+	})
+}
+
+// syncChangesWithRest pages through the changes with queryAll, handing each
+// page to handle
+func (s *SalesforceUtils) syncChangesWithRest(typeName string, soql string, handle func([]ChangeEvent) error) error {
+	response, err := s.ExecuteSoqlQueryAll(soql)
+	for {
+		if err != nil {
+			return errorx.Decorate(err, "failed to query changes of %s", typeName)
+		}
+		events := make([]ChangeEvent, 0, len(response.Records))
+		for _, record := range response.Records {
+			fields, ok := record.(map[string]interface{})
+			if !ok {
+				return errorx.IllegalFormat.New("unexpected record %v", record)
+			}
+			delete(fields, "attributes")
+			event, eventErr := newChangeEvent(typeName, fields)
+			if eventErr != nil {
+				return eventErr
+			}
+			events = append(events, event)
+		}
+		err = handle(events)
+		if err != nil {
+			return err
+		}
+		if response.Done || response.NextRecordsUrl == "" {
+			return nil
+		}
+		response, err = s.GetNextRecords(response.NextRecordsUrl)
+	}
+}
+
+// syncChangesWithBulk reads the changes with a bulk queryAll job, handing
+// each page of results to handle. the pages aren't in order with each other.
+func (s *SalesforceUtils) syncChangesWithBulk(typeName string, soql string, pollInterval time.Duration, handle func([]ChangeEvent) error) error {
+	job, err := s.CreateBulkQueryAllJob(soql)
+	if err != nil {
+		return errorx.Decorate(err, "failed to create bulk job for changes of %s", typeName)
+	}
+	_, err = s.WaitForBulkQueryJob(job.ID, pollInterval, 0)
+	if err != nil {
+		return err
+	}
+	locator := ""
+	for {
+		page, err := s.GetBulkQueryJobResults(job.ID, locator)
+		if err != nil {
+			return err
+		}
+		header, rows, err := readBulkResultCsv(page.Body)
+		if err != nil {
+			return err
+		}
+		events := make([]ChangeEvent, 0, len(rows))
+		for _, row := range rows {
+			fields := make(map[string]interface{}, len(header))
+			for i, column := range header {
+				if i < len(row) {
+					fields[column] = row[i]
+				}
+			}
+			event, eventErr := newChangeEvent(typeName, fields)
+			if eventErr != nil {
+				return eventErr
+			}
+			events = append(events, event)
+		}
+		err = handle(events)
+		if err != nil {
+			return err
+		}
+		if page.Locator == "" {
+			return nil
+		}
+		locator = page.Locator
+	}
+}
+
+// newChangeEvent builds a change event from the fields of a record, which
+// must include Id, SystemModstamp and IsDeleted
+func newChangeEvent(typeName string, fields map[string]interface{}) (ChangeEvent, error) {
+	event := ChangeEvent{ObjectType: typeName, Type: ChangeTypeUpsert, Record: fields}
+	event.Id, _ = fields["Id"].(string)
+	modstamp, _ := fields["SystemModstamp"].(string)
+	systemModstamp, err := types.ParseDateTime(modstamp)
+	if err != nil {
+		return event, errorx.Decorate(err, "record %s has an invalid SystemModstamp", event.Id)
+	}
+	event.SystemModstamp = systemModstamp.Time
+	switch isDeleted := fields["IsDeleted"].(type) {
+	case bool:
+		if isDeleted {
+			event.Type = ChangeTypeDelete
+		}
+	case string:
+		if isDeleted == "true" {
+			event.Type = ChangeTypeDelete
+		}
+	}
+	return event, nil
+}
+
+// changeSyncFields adds the fields a sync depends on to the selected fields
+func changeSyncFields(fields []string) []string {
+	selected := []string{"Id", "SystemModstamp", "IsDeleted"}
+	seen := map[string]bool{"id": true, "systemmodstamp": true, "isdeleted": true}
+	for _, field := range fields {
+		if !seen[strings.ToLower(field)] {
+			seen[strings.ToLower(field)] = true
+			selected = append(selected, field)
+		}
+	}
+	return selected
+}
+
+// changeSyncCondition builds the WHERE clause that selects the records after
+// the watermark. the literal drops the fractional seconds of the watermark,
+// which only ever selects more records, never fewer.
+func changeSyncCondition(watermark SyncWatermark, where string) string {
+	var conditions []string
+	if !watermark.SystemModstamp.IsZero() {
+		modstamp := watermark.SystemModstamp.UTC().Format(soqlDateTimeLayout)
+		if watermark.Id == "" {
+			conditions = append(conditions, fmt.Sprintf("SystemModstamp >= %s", modstamp))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(SystemModstamp > %s OR (SystemModstamp = %s AND Id > '%s'))", modstamp, modstamp, watermark.Id))
+		}
+	}
+	if where != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s)", where))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" WHERE %s", strings.Join(conditions, " AND "))
+}
@@ -25,14 +25,17 @@ type CollectionsResponseItem struct {
 	Id      string                     `json:"id"`
 	Success bool                       `json:"success"`
 	Errors  []CollectionsResponseError `json:"errors"`
+	// Created is only set by upserts, true when the record was created
+	// rather than updated
+	Created bool `json:"created"`
 }
 
 // CollectionsResponseError is the error response item for a single object in
 // the response from the collections api
 type CollectionsResponseError struct {
-	StatusCode string   `json:"statusCode"`
-	Message    string   `json:"message"`
-	Fields     []string `json:"fields"`
+	StatusCode string   `json:"statusCode" xml:"statusCode"`
+	Message    string   `json:"message" xml:"message"`
+	Fields     []string `json:"fields" xml:"fields"`
 }
 
 // CollectionsCreateObjects creates objects in salesforce using the composite
@@ -55,7 +58,7 @@ func (s *SalesforceUtils) CollectionsCreateObjects(recordsJsonBytes [][]byte) (r
 		return nil, err
 	}
 
-	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes)
+	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes, true)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +87,7 @@ func (s *SalesforceUtils) CollectionsUpdateObjects(recordsJsonBytes [][]byte) (r
 		return nil, err
 	}
 
-	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes)
+	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes, true)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +95,37 @@ func (s *SalesforceUtils) CollectionsUpdateObjects(recordsJsonBytes [][]byte) (r
 	return s.doCollectionsRequest(s.getCollectionsUrl(), fasthttp.MethodPatch, body)
 }
 
+// CollectionsUpsertObjects creates or updates objects in salesforce using the
+// composite "collections" api, matching existing records on externalIdField.
+// like CollectionsCreateObjects the objects must be marshalled to json with an
+// attributes field, and every object must be of typeName and have a value for
+// externalIdField. the Created field of each response item tells whether the
+// record was created or updated.
+//
+// ex:
+//
+//	{
+//	  "attributes" : {"type" : "Account"},
+//	  "External_Id__c" : "ext-123",
+//	  "Name" : "Example"
+//	  ...
+//	}
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_sobjects_collections_upsert.htm
+func (s *SalesforceUtils) CollectionsUpsertObjects(typeName string, externalIdField string, recordsJsonBytes [][]byte) (response []CollectionsResponseItem, err error) {
+	err = validateCollectionsRequestLength(len(recordsJsonBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.doCollectionsRequest(s.getCollectionsUpsertUrl(typeName, externalIdField), fasthttp.MethodPatch, body)
+}
+
 // CollectionsDeleteRequest is used by the CollectionsDeleteObjects method when
 // interacting with the composite collections api
 type CollectionsDeleteRequest struct {
@@ -106,7 +140,7 @@ func (s *SalesforceUtils) CollectionsDeleteObjects(ids []string) (response []Col
 	if err != nil {
 		return nil, err
 	}
-	deleteUrl := s.getCollectionsDeleteUrl(ids)
+	deleteUrl := s.getCollectionsDeleteUrl(ids, true)
 	return s.doCollectionsRequest(deleteUrl, fasthttp.MethodDelete, nil)
 }
 
@@ -116,6 +150,26 @@ func (s *SalesforceUtils) CollectionsDeleteObjects(ids []string) (response []Col
 // query parameters. this parameterizes the url, method, and body so each
 // method can make use of it. everything else is the same.
 func (s *SalesforceUtils) doCollectionsRequest(url string, method string, body []byte) (response []CollectionsResponseItem, err error) {
+	response, err = s.sendCollectionsRequest(url, method, body)
+	if err != nil {
+		return response, err
+	}
+
+	// check for errors in the response and return an error if any are found so
+	// that the caller doesn't have to check the response for errors
+	for _, respItem := range response {
+		if !respItem.Success || len(respItem.Errors) > 0 {
+			// return the first error, since allOrNone is always true
+			return response, errorx.IllegalState.New("failed to update object: %s", respItem.Errors)
+		}
+	}
+
+	return response, nil
+}
+
+// sendCollectionsRequest sends a request to the composite collections api and
+// unmarshals the per-record results without checking them for errors
+func (s *SalesforceUtils) sendCollectionsRequest(url string, method string, body []byte) (response []CollectionsResponseItem, err error) {
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 	req.SetRequestURI(url)
@@ -139,21 +193,12 @@ func (s *SalesforceUtils) doCollectionsRequest(url string, method string, body [
 		return response, errorx.IllegalState.New("failed to unmarshal response: %s", err)
 	}
 
-	// check for errors in the response and return an error if any are found so
-	// that the caller doesn't have to check the response for errors
-	for _, respItem := range response {
-		if !respItem.Success || len(respItem.Errors) > 0 {
-			// return the first error, since allOrNone is always true
-			return response, errorx.IllegalState.New("failed to update object: %s", respItem.Errors)
-		}
-	}
-
 	return response, nil
 }
 
-func jsonRecordsToCollectionsRequestJson(recordsJsonBytes [][]byte) ([]byte, error) {
+func jsonRecordsToCollectionsRequestJson(recordsJsonBytes [][]byte, allOrNone bool) ([]byte, error) {
 	collectionsReq := CollectionsRequest{
-		AllOrNone: true,
+		AllOrNone: allOrNone,
 	}
 	for _, recordJsonBytes := range recordsJsonBytes {
 		collectionsReq.Records = append(collectionsReq.Records, recordJsonBytes)
@@ -169,21 +214,27 @@ func validateCollectionsRequestLength(length int) error {
 	if length == 0 {
 		return errorx.IllegalArgument.New("input must not be empty")
 	}
-	if length > 200 {
-		return errorx.IllegalArgument.New("input must not be larger than 200")
+	if length > collectionsMaxRecords {
+		return errorx.IllegalArgument.New("input must not be larger than %d", collectionsMaxRecords)
 	}
 	return nil
 }
 
 // getDeleteUrl gets a formatted full url to the collections api for deleting.
-// builds query parameters to include each id and adds the allOrNone=true
+// builds query parameters to include each id and adds the allOrNone
 // parameter.
-func (s *SalesforceUtils) getCollectionsDeleteUrl(ids []string) string {
+func (s *SalesforceUtils) getCollectionsDeleteUrl(ids []string, allOrNone bool) string {
 	idsAsCommaSeparatedString := strings.Join(ids, ",")
-	queryParams := fmt.Sprintf("?allOrNone=true&ids=%s", idsAsCommaSeparatedString)
+	queryParams := fmt.Sprintf("?allOrNone=%t&ids=%s", allOrNone, idsAsCommaSeparatedString)
 	return fmt.Sprintf("%s%s", s.getCollectionsUrl(), queryParams)
 }
 
+// getCollectionsUpsertUrl gets a formatted full url to the collections api
+// for upserting objects of a type by an external id field.
+func (s *SalesforceUtils) getCollectionsUpsertUrl(typeName string, externalIdField string) string {
+	return fmt.Sprintf("%s/%s/%s", s.getCollectionsUrl(), typeName, externalIdField)
+}
+
 // getCollectionsUrl gets a formatted full url to the collections api.
 func (s *SalesforceUtils) getCollectionsUrl() string {
 	return fmt.Sprintf("%s/services/data/v%s/composite/sobjects", s.Config.BaseUrl, s.Config.ApiVersion)
@@ -0,0 +1,55 @@
+package pkg
+
+// collectionsMaxRecords is the maximum number of records salesforce accepts
+// in a single collections request
+const collectionsMaxRecords = 200
+
+// CollectionsCreateObjectsBatched is the same as CollectionsCreateObjects but
+// accepts any number of records. the records are split into requests of 200
+// and up to concurrency requests are sent at the same time.
+//
+// each request is still allOrNone, so a failure rolls back only the records
+// in its own batch while every other batch is committed. the results are
+// returned in input order; the results of a batch that failed before
+// salesforce answered are left as zero values. if any batch fails a
+// *ChunkedRequestError is returned that lists the failed input ranges, so
+// they can be retried.
+func (s *SalesforceUtils) CollectionsCreateObjectsBatched(recordsJsonBytes [][]byte, concurrency int) ([]CollectionsResponseItem, error) {
+	return doCollectionsBatches(len(recordsJsonBytes), concurrency, func(start, end int) ([]CollectionsResponseItem, error) {
+		return s.CollectionsCreateObjects(recordsJsonBytes[start:end])
+	})
+}
+
+// CollectionsUpdateObjectsBatched is the same as CollectionsUpdateObjects but
+// accepts any number of records. see CollectionsCreateObjectsBatched for how
+// batches and failures are handled.
+func (s *SalesforceUtils) CollectionsUpdateObjectsBatched(recordsJsonBytes [][]byte, concurrency int) ([]CollectionsResponseItem, error) {
+	return doCollectionsBatches(len(recordsJsonBytes), concurrency, func(start, end int) ([]CollectionsResponseItem, error) {
+		return s.CollectionsUpdateObjects(recordsJsonBytes[start:end])
+	})
+}
+
+// CollectionsDeleteObjectsBatched is the same as CollectionsDeleteObjects but
+// accepts any number of ids. see CollectionsCreateObjectsBatched for how
+// batches and failures are handled.
+func (s *SalesforceUtils) CollectionsDeleteObjectsBatched(ids []string, concurrency int) ([]CollectionsResponseItem, error) {
+	return doCollectionsBatches(len(ids), concurrency, func(start, end int) ([]CollectionsResponseItem, error) {
+		return s.CollectionsDeleteObjects(ids[start:end])
+	})
+}
+
+// doCollectionsBatches splits length input items into batches of 200, calls
+// request for each batch with bounded concurrency and places the results of
+// each batch at the batch's position in the input
+func doCollectionsBatches(length int, concurrency int, request func(start, end int) ([]CollectionsResponseItem, error)) ([]CollectionsResponseItem, error) {
+	results := make([]CollectionsResponseItem, length)
+	chunks := chunkRanges(length, collectionsMaxRecords)
+	errs := make([]error, len(chunks))
+	forEachConcurrently(len(chunks), concurrency, func(i int) {
+		chunk := chunks[i]
+		response, err := request(chunk.start, chunk.end)
+		copy(results[chunk.start:chunk.end], response)
+		errs[i] = err
+	})
+	return results, newChunkedRequestError(chunks, errs)
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// CollectionsRecordResult pairs a single input record of a partial success
+// collections request with its result
+type CollectionsRecordResult struct {
+	// Index is the position of the record in the input
+	Index int
+	// Record is the input record json, nil for deletes
+	Record []byte
+	// Id is the input id for deletes, for creates and updates the id is on
+	// Result
+	Id     string
+	Result CollectionsResponseItem
+}
+
+// Succeeded reports whether salesforce saved the record
+func (r CollectionsRecordResult) Succeeded() bool {
+	return r.Result.Success && len(r.Result.Errors) == 0
+}
+
+// CollectionsPartialResult is the outcome of a collections request sent with
+// allOrNone=false, where each record succeeds or fails on its own
+type CollectionsPartialResult struct {
+	Results []CollectionsRecordResult
+}
+
+// Successes gets the records that were saved
+func (r CollectionsPartialResult) Successes() []CollectionsRecordResult {
+	var successes []CollectionsRecordResult
+	for _, result := range r.Results {
+		if result.Succeeded() {
+			successes = append(successes, result)
+		}
+	}
+	return successes
+}
+
+// Failures gets the records that were not saved
+func (r CollectionsPartialResult) Failures() []CollectionsRecordResult {
+	var failures []CollectionsRecordResult
+	for _, result := range r.Results {
+		if !result.Succeeded() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Err returns an error describing the first failed record, or nil if every
+// record was saved. use it when any failed record should be treated as fatal.
+func (r CollectionsPartialResult) Err() error {
+	failures := r.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	return errorx.IllegalState.New("%d of %d records failed, first failure at index %d: %s", len(failures), len(r.Results), failures[0].Index, failures[0].Result.Errors)
+}
+
+// CollectionsCreateObjectsPartial is the same as CollectionsCreateObjects but
+// sends allOrNone=false, so records that fail don't prevent the others from
+// being created. an error is only returned if the request itself fails, the
+// outcome of each record is in the result.
+func (s *SalesforceUtils) CollectionsCreateObjectsPartial(recordsJsonBytes [][]byte) (CollectionsPartialResult, error) {
+	return s.doCollectionsPartialRequest(s.getCollectionsUrl(), recordsJsonBytes, fasthttp.MethodPost)
+}
+
+// CollectionsUpdateObjectsPartial is the same as CollectionsUpdateObjects but
+// sends allOrNone=false, so records that fail don't prevent the others from
+// being updated. an error is only returned if the request itself fails, the
+// outcome of each record is in the result.
+func (s *SalesforceUtils) CollectionsUpdateObjectsPartial(recordsJsonBytes [][]byte) (CollectionsPartialResult, error) {
+	return s.doCollectionsPartialRequest(s.getCollectionsUrl(), recordsJsonBytes, fasthttp.MethodPatch)
+}
+
+// CollectionsUpsertObjectsPartial is the same as CollectionsUpsertObjects
+// but sends allOrNone=false, so records that fail don't prevent the others
+// from being upserted. an error is only returned if the request itself fails,
+// the outcome of each record is in the result.
+func (s *SalesforceUtils) CollectionsUpsertObjectsPartial(typeName string, externalIdField string, recordsJsonBytes [][]byte) (CollectionsPartialResult, error) {
+	return s.doCollectionsPartialRequest(s.getCollectionsUpsertUrl(typeName, externalIdField), recordsJsonBytes, fasthttp.MethodPatch)
+}
+
+// CollectionsDeleteObjectsPartial is the same as CollectionsDeleteObjects but
+// sends allOrNone=false, so ids that fail don't prevent the others from being
+// deleted. an error is only returned if the request itself fails, the outcome
+// of each id is in the result.
+func (s *SalesforceUtils) CollectionsDeleteObjectsPartial(ids []string) (result CollectionsPartialResult, err error) {
+	err = validateCollectionsRequestLength(len(ids))
+	if err != nil {
+		return
+	}
+	response, err := s.sendCollectionsRequest(s.getCollectionsDeleteUrl(ids, false), fasthttp.MethodDelete, nil)
+	if err != nil {
+		return
+	}
+	err = validateCollectionsResponseLength(len(ids), len(response))
+	if err != nil {
+		return
+	}
+	for i, item := range response {
+		result.Results = append(result.Results, CollectionsRecordResult{Index: i, Id: ids[i], Result: item})
+	}
+	return
+}
+
+func (s *SalesforceUtils) doCollectionsPartialRequest(url string, recordsJsonBytes [][]byte, method string) (result CollectionsPartialResult, err error) {
+	err = validateCollectionsRequestLength(len(recordsJsonBytes))
+	if err != nil {
+		return
+	}
+	body, err := jsonRecordsToCollectionsRequestJson(recordsJsonBytes, false)
+	if err != nil {
+		return
+	}
+	response, err := s.sendCollectionsRequest(url, method, body)
+	if err != nil {
+		return
+	}
+	err = validateCollectionsResponseLength(len(recordsJsonBytes), len(response))
+	if err != nil {
+		return
+	}
+	for i, item := range response {
+		result.Results = append(result.Results, CollectionsRecordResult{Index: i, Record: recordsJsonBytes[i], Result: item})
+	}
+	return
+}
+
+// validateCollectionsResponseLength checks that salesforce returned one result
+// per input record, which is needed to pair them up by position
+func validateCollectionsResponseLength(requestLength int, responseLength int) error {
+	if requestLength != responseLength {
+		return errorx.IllegalState.New("got %d results for %d records", responseLength, requestLength)
+	}
+	return nil
+}
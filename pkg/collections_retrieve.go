@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// collectionsMaxRetrieveIds is the maximum number of ids salesforce accepts
+// in a single collections retrieve request
+const collectionsMaxRetrieveIds = 2000
+
+// CollectionsRetrieveRequest is used by CollectionsRetrieveObjects when
+// retrieving records with the composite collections api
+type CollectionsRetrieveRequest struct {
+	Ids    []string `json:"ids"`
+	Fields []string `json:"fields"`
+}
+
+// CollectionsRetrieveObjects retrieves records of a single type by id using
+// the composite "collections" api. the ids are sent in the request body, so
+// there is no url length limit. more than 2000 ids are split into multiple
+// requests, with up to concurrency requests sent at the same time.
+//
+// the raw json of each record is returned in the same order as ids. a record
+// that doesn't exist or isn't visible to the user is returned as nil. if any
+// request fails a *ChunkedRequestError is returned that lists the failed
+// input ranges, the records of every other request are still returned.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_sobjects_collections_retrieve.htm
+func (s *SalesforceUtils) CollectionsRetrieveObjects(typeName string, ids []string, fields []string, concurrency int) ([]json.RawMessage, error) {
+	if len(fields) == 0 {
+		return nil, errorx.IllegalArgument.New("fields must not be empty")
+	}
+	results := make([]json.RawMessage, len(ids))
+	chunks := chunkRanges(len(ids), collectionsMaxRetrieveIds)
+	errs := make([]error, len(chunks))
+	forEachConcurrently(len(chunks), concurrency, func(i int) {
+		chunk := chunks[i]
+		var response []json.RawMessage
+		response, errs[i] = s.doCollectionsRetrieveRequest(typeName, ids[chunk.start:chunk.end], fields)
+		copy(results[chunk.start:chunk.end], response)
+	})
+	return results, newChunkedRequestError(chunks, errs)
+}
+
+// CollectionsRetrieve is the same as CollectionsRetrieveObjects but decodes
+// each record into T, which can be a struct or a map. records that weren't
+// found are returned as nil.
+func CollectionsRetrieve[T any](s *SalesforceUtils, typeName string, ids []string, fields []string, concurrency int) ([]*T, error) {
+	raw, requestErr := s.CollectionsRetrieveObjects(typeName, ids, fields, concurrency)
+	if raw == nil {
+		return nil, requestErr
+	}
+	records := make([]*T, len(raw))
+	for i, recordJson := range raw {
+		if recordJson == nil {
+			continue
+		}
+		records[i] = new(T)
+		err := json.Unmarshal(recordJson, records[i])
+		if err != nil {
+			return nil, errorx.Decorate(err, "failed to unmarshal record %s", ids[i])
+		}
+	}
+	return records, requestErr
+}
+
+// doCollectionsRetrieveRequest retrieves a single chunk of up to 2000 ids,
+// returning nil for the ids that weren't found
+func (s *SalesforceUtils) doCollectionsRetrieveRequest(typeName string, ids []string, fields []string) ([]json.RawMessage, error) {
+	reqBody, err := json.Marshal(CollectionsRetrieveRequest{Ids: ids, Fields: fields})
+	if err != nil {
+		return nil, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getCollectionsRetrieveUrl(typeName))
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(reqBody)
+
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != fasthttp.StatusOK {
+		return nil, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+
+	// unmarshalling into json.RawMessage copies the bytes, so the records
+	// outlive the response
+	var response []json.RawMessage
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, errorx.IllegalState.New("failed to unmarshal response: %s", err)
+	}
+	err = validateCollectionsResponseLength(len(ids), len(response))
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range response {
+		if bytes.Equal(record, []byte("null")) {
+			response[i] = nil
+		}
+	}
+	return response, nil
+}
+
+// getCollectionsRetrieveUrl gets a formatted full url to the collections api
+// for retrieving objects of a type
+func (s *SalesforceUtils) getCollectionsRetrieveUrl(typeName string) string {
+	return fmt.Sprintf("%s/%s", s.getCollectionsUrl(), typeName)
+}
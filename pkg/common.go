@@ -2,14 +2,151 @@ package pkg
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/valyala/fasthttp"
 )
 
 // sendRequest sends a configured request, returning the body, status code, and error
 func (s *SalesforceUtils) sendRequest(req *fasthttp.Request) ([]byte, int, func(), error) {
+	res, deferredFunc, err := s.sendRequestForResponse(req)
+	return res.Body(), res.StatusCode(), deferredFunc, err
+}
+
+// sendRequestForResponse sends a configured request, returning the whole
+// response for callers that need its headers. the response is only valid
+// until the returned func is called.
+func (s *SalesforceUtils) sendRequestForResponse(req *fasthttp.Request) (*fasthttp.Response, func(), error) {
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Credentials.AccessToken))
 	res := fasthttp.AcquireResponse()
 	err := s.FastHTTPClient.Do(req, res)
-	return res.Body(), res.StatusCode(), func() { fasthttp.ReleaseResponse(res) }, err
+	return res, func() { fasthttp.ReleaseResponse(res) }, err
+}
+
+// streamingMaxBufferedBodySize is the largest response body the streaming
+// client reads into memory. fasthttp reads any body with a Content-Length up
+// to the client's MaxResponseBodySize in full, and only streams bodies larger
+// than it.
+const streamingMaxBufferedBodySize = 64 * 1024
+
+// newStreamingClient creates a client with the connection settings of client
+// that streams response bodies larger than streamingMaxBufferedBodySize
+func newStreamingClient(client *fasthttp.Client) *fasthttp.Client {
+	return &fasthttp.Client{
+		Name:                          client.Name,
+		NoDefaultUserAgentHeader:      client.NoDefaultUserAgentHeader,
+		Dial:                          client.Dial,
+		DialDualStack:                 client.DialDualStack,
+		TLSConfig:                     client.TLSConfig,
+		MaxConnsPerHost:               client.MaxConnsPerHost,
+		MaxIdleConnDuration:           client.MaxIdleConnDuration,
+		MaxConnDuration:               client.MaxConnDuration,
+		MaxIdemponentCallAttempts:     client.MaxIdemponentCallAttempts,
+		ReadBufferSize:                client.ReadBufferSize,
+		WriteBufferSize:               client.WriteBufferSize,
+		ReadTimeout:                   client.ReadTimeout,
+		WriteTimeout:                  client.WriteTimeout,
+		MaxConnWaitTimeout:            client.MaxConnWaitTimeout,
+		DisableHeaderNamesNormalizing: client.DisableHeaderNamesNormalizing,
+		DisablePathNormalizing:        client.DisablePathNormalizing,
+		RetryIf:                       client.RetryIf,
+		ConnPoolStrategy:              client.ConnPoolStrategy,
+		MaxResponseBodySize:           streamingMaxBufferedBodySize,
+		StreamResponseBody:            true,
+	}
+}
+
+// sendStreamingRequest sends a configured request through the streaming
+// client, so a large response body isn't read into memory and has to be read
+// from the response's BodyStream. the response is only valid until the
+// returned func is called.
+func (s *SalesforceUtils) sendStreamingRequest(req *fasthttp.Request) (*fasthttp.Response, func(), error) {
+	client := s.StreamingHTTPClient
+	if client == nil {
+		client = newStreamingClient(s.FastHTTPClient)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Credentials.AccessToken))
+	res := fasthttp.AcquireResponse()
+	err := client.Do(req, res)
+	return res, func() { fasthttp.ReleaseResponse(res) }, err
+}
+
+// forEachConcurrently calls fn once for every index in [0, count), running at
+// most concurrency calls at the same time. it blocks until every call has
+// returned. a concurrency below 1 is treated as 1.
+func forEachConcurrently(count int, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// chunkRange is the half open range [start, end) of input items in a chunk
+type chunkRange struct {
+	start int
+	end   int
+}
+
+// chunkRanges splits length input items into chunks of at most size items
+func chunkRanges(length int, size int) []chunkRange {
+	var chunks []chunkRange
+	for start := 0; start < length; start += size {
+		end := start + size
+		if end > length {
+			end = length
+		}
+		chunks = append(chunks, chunkRange{start: start, end: end})
+	}
+	return chunks
+}
+
+// ChunkFailure is a single chunk of a chunked request that failed
+type ChunkFailure struct {
+	// Offset is the index of the chunk's first input item
+	Offset int
+	// Length is the number of input items in the chunk
+	Length int
+	Err    error
+}
+
+// ChunkedRequestError is returned by chunked requests when one or more chunks
+// fail. chunks are sent as independent requests, so every chunk that isn't
+// listed in Failures succeeded.
+type ChunkedRequestError struct {
+	Failures []ChunkFailure
+}
+
+func (e *ChunkedRequestError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = fmt.Sprintf("items %d-%d: %s", failure.Offset, failure.Offset+failure.Length-1, failure.Err)
+	}
+	return fmt.Sprintf("%d chunks failed: %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+// newChunkedRequestError builds a ChunkedRequestError from the per-chunk
+// errors, returning nil if no chunk failed
+func newChunkedRequestError(chunks []chunkRange, errs []error) error {
+	var failures []ChunkFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, ChunkFailure{Offset: chunks[i].start, Length: chunks[i].end - chunks[i].start, Err: err})
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &ChunkedRequestError{Failures: failures}
 }
@@ -1,8 +1,10 @@
 package pkg
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/joomcode/errorx"
 	"github.com/valyala/fasthttp"
@@ -47,17 +49,130 @@ type CompositeResponse struct {
 }
 
 type CompositeSubResponse struct {
-	Body           CompositeSubResponseBody `json:"body"`
-	HttpStatusCode int                      `json:"httpStatusCode"`
-	ReferenceId    string                   `json:"referenceId"`
+	// Body is kept as raw json since its shape depends on the subrequest and
+	// on whether it succeeded. use Result, Errors or DecodeBody to decode it.
+	Body           json.RawMessage   `json:"body"`
+	HttpHeaders    map[string]string `json:"httpHeaders"`
+	HttpStatusCode int               `json:"httpStatusCode"`
+	ReferenceId    string            `json:"referenceId"`
 }
 
+// CompositeSubResponseBody is the body returned by successful create and
+// update subrequests
 type CompositeSubResponseBody struct {
-	Id      string `json:"id"`
-	Success bool   `json:"success"`
-	// TODO strongly type this when we figure out the possibilities. the docs
-	// don't specify what this field is supposed to be
-	Errors []interface{} `json:"errors"`
+	Id      string           `json:"id"`
+	Success bool             `json:"success"`
+	Errors  []CompositeError `json:"errors"`
+}
+
+// CompositeError is a single error returned by a subrequest
+type CompositeError struct {
+	ErrorCode string   `json:"errorCode"`
+	Message   string   `json:"message"`
+	Fields    []string `json:"fields"`
+}
+
+// UnmarshalJSON accepts the statusCode key used by the errors inside a
+// CompositeSubResponseBody as well as the errorCode key used by failed
+// subrequests
+func (c *CompositeError) UnmarshalJSON(data []byte) error {
+	var compositeError struct {
+		ErrorCode  string   `json:"errorCode"`
+		StatusCode string   `json:"statusCode"`
+		Message    string   `json:"message"`
+		Fields     []string `json:"fields"`
+	}
+	err := json.Unmarshal(data, &compositeError)
+	if err != nil {
+		return err
+	}
+	c.ErrorCode = compositeError.ErrorCode
+	if c.ErrorCode == "" {
+		c.ErrorCode = compositeError.StatusCode
+	}
+	c.Message = compositeError.Message
+	c.Fields = compositeError.Fields
+	return nil
+}
+
+func (c CompositeError) Error() string {
+	if len(c.Fields) > 0 {
+		return fmt.Sprintf("%s: %s (fields: %s)", c.ErrorCode, c.Message, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("%s: %s", c.ErrorCode, c.Message)
+}
+
+// Succeeded reports whether the subrequest returned a 2xx status code
+func (c CompositeSubResponse) Succeeded() bool {
+	return c.HttpStatusCode >= 200 && c.HttpStatusCode <= 299
+}
+
+// Result decodes the body of a successful create or update subrequest
+func (c CompositeSubResponse) Result() (result CompositeSubResponseBody, err error) {
+	if !c.Succeeded() {
+		err = errorx.IllegalState.New("subrequest %s failed with status code: %d and body: %s", c.ReferenceId, c.HttpStatusCode, c.Body)
+		return
+	}
+	err = c.DecodeBody(&result)
+	return
+}
+
+// Errors decodes the errors of the subrequest. failed subrequests return a
+// list of errors as their body, while successful ones may carry errors inside
+// a CompositeSubResponseBody. nil is returned when there are no errors.
+func (c CompositeSubResponse) Errors() ([]CompositeError, error) {
+	body := bytes.TrimSpace(c.Body)
+	if len(body) == 0 {
+		return nil, nil
+	}
+	switch body[0] {
+	case '[':
+		var compositeErrors []CompositeError
+		err := json.Unmarshal(body, &compositeErrors)
+		return compositeErrors, err
+	case '{':
+		var result CompositeSubResponseBody
+		err := json.Unmarshal(body, &result)
+		return result.Errors, err
+	}
+	return nil, nil
+}
+
+// DecodeBody unmarshals the body of the sub response into v
+func (c CompositeSubResponse) DecodeBody(v interface{}) error {
+	if len(c.Body) == 0 {
+		return errorx.IllegalState.New("sub response %s has no body", c.ReferenceId)
+	}
+	return json.Unmarshal(c.Body, v)
+}
+
+// RootCauseError returns the error that caused the composite request to
+// fail, or nil if every subrequest succeeded. when allOrNone rolls back the
+// request every other subrequest fails with PROCESSING_HALTED, those are
+// skipped so the error names the subrequest that actually failed.
+func (c CompositeResponse) RootCauseError() error {
+	var halted *CompositeSubResponse
+	for i, subResponse := range c.CompositeResponse {
+		if subResponse.Succeeded() {
+			continue
+		}
+		compositeErrors, err := subResponse.Errors()
+		if err != nil {
+			return errorx.IllegalState.New("subrequest %s failed with status code: %d and body: %s", subResponse.ReferenceId, subResponse.HttpStatusCode, subResponse.Body)
+		}
+		for _, compositeError := range compositeErrors {
+			if compositeError.ErrorCode != processingHaltedStatusCode {
+				return errorx.IllegalState.New("subrequest %s failed with status code: %d: %s", subResponse.ReferenceId, subResponse.HttpStatusCode, compositeError)
+			}
+		}
+		if halted == nil {
+			halted = &c.CompositeResponse[i]
+		}
+	}
+	if halted != nil {
+		return errorx.IllegalState.New("subrequest %s failed with status code: %d and body: %s", halted.ReferenceId, halted.HttpStatusCode, halted.Body)
+	}
+	return nil
 }
 
 // CompositeCreateObjects creates a list of objects in salesforce using the
@@ -186,7 +301,41 @@ func (s *SalesforceUtils) convertToCompositeDeleteRequest(objects []CompositeObj
 	return compositeReq
 }
 
+// limits of the composite api for a single request
+const (
+	compositeMaxSubrequests = 25
+	// compositeMaxCollectionsOrQuerySubrequests is the maximum number of
+	// sObject collections and query subrequests in a single request
+	compositeMaxCollectionsOrQuerySubrequests = 5
+)
+
+// validateCompositeRequest checks the subrequests of a composite request
+// against the limits of the composite api, so an oversized request fails
+// before it's sent. an empty request is left for salesforce to answer, as it
+// always has been.
+func (s *SalesforceUtils) validateCompositeRequest(compositeRequest CompositeRequest) error {
+	if len(compositeRequest.CompositeRequest) > compositeMaxSubrequests {
+		return errorx.IllegalArgument.New("composite requests must not have more than %d subrequests, got %d", compositeMaxSubrequests, len(compositeRequest.CompositeRequest))
+	}
+	dataPath := fmt.Sprintf("/services/data/v%s/", s.Config.ApiVersion)
+	collectionsOrQueryCount := 0
+	for _, subRequest := range compositeRequest.CompositeRequest {
+		path := strings.TrimPrefix(subRequest.Url, dataPath)
+		if strings.HasPrefix(path, "composite/sobjects") || strings.HasPrefix(path, "query") {
+			collectionsOrQueryCount++
+		}
+	}
+	if collectionsOrQueryCount > compositeMaxCollectionsOrQuerySubrequests {
+		return errorx.IllegalArgument.New("composite requests must not have more than %d sObject collections or query subrequests, got %d", compositeMaxCollectionsOrQuerySubrequests, collectionsOrQueryCount)
+	}
+	return nil
+}
+
 func (s *SalesforceUtils) doCompositeRequest(compositeRequest CompositeRequest) (response CompositeResponse, err error) {
+	err = s.validateCompositeRequest(compositeRequest)
+	if err != nil {
+		return response, err
+	}
 	reqBodyBytes, err := json.Marshal(compositeRequest)
 	if err != nil {
 		return response, err
@@ -0,0 +1,224 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// compositeBatchMaxSubrequests is the maximum number of subrequests salesforce
+// accepts in a single batch request
+const compositeBatchMaxSubrequests = 25
+
+// CompositeBatchSubRequest is a single subrequest of a batch request
+type CompositeBatchSubRequest struct {
+	Method string `json:"method"`
+	// Url is relative to /services/data, e.g. "v55.0/sobjects/Account"
+	Url string `json:"url"`
+	// RichInput is the body of POST and PATCH subrequests
+	RichInput json.RawMessage `json:"richInput,omitempty"`
+}
+
+// CompositeBatchRequest is the body of a batch request. unlike the composite
+// api the subrequests are independent, each succeeds or fails on its own and
+// they can't reference each other.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_batch.htm
+type CompositeBatchRequest struct {
+	// HaltOnError stops processing the remaining subrequests when one fails.
+	// salesforce answers the skipped subrequests with a 412 status code.
+	HaltOnError   bool                       `json:"haltOnError"`
+	BatchRequests []CompositeBatchSubRequest `json:"batchRequests"`
+}
+
+// CompositeBatchResponse is the response from a batch request. the results are
+// in the same order as the subrequests.
+type CompositeBatchResponse struct {
+	HasErrors bool                        `json:"hasErrors"`
+	Results   []CompositeBatchSubResponse `json:"results"`
+}
+
+// CompositeBatchSubResponse is the result of a single subrequest. Result is
+// kept as raw json so it can be decoded into whatever type the subrequest
+// returns.
+type CompositeBatchSubResponse struct {
+	StatusCode int             `json:"statusCode"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// Succeeded reports whether the subrequest returned a 2xx status code
+func (r CompositeBatchSubResponse) Succeeded() bool {
+	return r.StatusCode >= 200 && r.StatusCode <= 299
+}
+
+// Decode unmarshals the result into v. an error is returned instead if the
+// subrequest didn't succeed or returned no result.
+func (r CompositeBatchSubResponse) Decode(v interface{}) error {
+	if !r.Succeeded() {
+		return errorx.IllegalState.New("subrequest failed with status code: %d and result: %s", r.StatusCode, r.Result)
+	}
+	if len(r.Result) == 0 || string(r.Result) == "null" {
+		return errorx.IllegalState.New("subrequest returned no result with status code: %d", r.StatusCode)
+	}
+	return json.Unmarshal(r.Result, v)
+}
+
+// ExecuteCompositeBatchRequest sends a batch request
+func (s *SalesforceUtils) ExecuteCompositeBatchRequest(batchRequest CompositeBatchRequest) (response CompositeBatchResponse, err error) {
+	if len(batchRequest.BatchRequests) == 0 {
+		return response, errorx.IllegalArgument.New("input must not be empty")
+	}
+	if len(batchRequest.BatchRequests) > compositeBatchMaxSubrequests {
+		return response, errorx.IllegalArgument.New("input must not be larger than %d", compositeBatchMaxSubrequests)
+	}
+	reqBodyBytes, err := json.Marshal(batchRequest)
+	if err != nil {
+		return response, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getCompositeBatchUrl())
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(reqBodyBytes)
+
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return response, err
+	}
+	if statusCode != fasthttp.StatusOK {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, errorx.IllegalState.New("failed to unmarshal response: %s", err)
+	}
+	return response, nil
+}
+
+func (s *SalesforceUtils) getCompositeBatchUrl() string {
+	return fmt.Sprintf("%s/batch", s.getCompositeUrl())
+}
+
+// CompositeBatchBuilder builds a batch request. results are returned in the
+// order the subrequests were added. the builder methods can be chained, the
+// first error is kept and returned by Build or Execute.
+type CompositeBatchBuilder struct {
+	s       *SalesforceUtils
+	request CompositeBatchRequest
+	err     error
+}
+
+// NewCompositeBatchBuilder creates an empty CompositeBatchBuilder
+func (s *SalesforceUtils) NewCompositeBatchBuilder(haltOnError bool) *CompositeBatchBuilder {
+	return &CompositeBatchBuilder{
+		s:       s,
+		request: CompositeBatchRequest{HaltOnError: haltOnError, BatchRequests: []CompositeBatchSubRequest{}},
+	}
+}
+
+// Add adds a subrequest as is
+func (b *CompositeBatchBuilder) Add(subRequest CompositeBatchSubRequest) *CompositeBatchBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(b.request.BatchRequests) >= compositeBatchMaxSubrequests {
+		b.err = errorx.IllegalArgument.New("batch requests must not have more than %d subrequests", compositeBatchMaxSubrequests)
+		return b
+	}
+	b.request.BatchRequests = append(b.request.BatchRequests, subRequest)
+	return b
+}
+
+// Get adds a GET subrequest for a path relative to the versioned data
+// endpoint, e.g. "sobjects/Account/describe"
+func (b *CompositeBatchBuilder) Get(path string) *CompositeBatchBuilder {
+	return b.Add(CompositeBatchSubRequest{Method: http.MethodGet, Url: b.versionedPath(path)})
+}
+
+// Post adds a POST subrequest for a path relative to the versioned data
+// endpoint
+func (b *CompositeBatchBuilder) Post(path string, body []byte) *CompositeBatchBuilder {
+	return b.Add(CompositeBatchSubRequest{Method: http.MethodPost, Url: b.versionedPath(path), RichInput: body})
+}
+
+// Patch adds a PATCH subrequest for a path relative to the versioned data
+// endpoint
+func (b *CompositeBatchBuilder) Patch(path string, body []byte) *CompositeBatchBuilder {
+	return b.Add(CompositeBatchSubRequest{Method: http.MethodPatch, Url: b.versionedPath(path), RichInput: body})
+}
+
+// Delete adds a DELETE subrequest for a path relative to the versioned data
+// endpoint
+func (b *CompositeBatchBuilder) Delete(path string) *CompositeBatchBuilder {
+	return b.Add(CompositeBatchSubRequest{Method: http.MethodDelete, Url: b.versionedPath(path)})
+}
+
+// Query adds a SOQL query subrequest, the result decodes into a SoqlResponse
+func (b *CompositeBatchBuilder) Query(query string) *CompositeBatchBuilder {
+	return b.Get(fmt.Sprintf("query?%s", url.Values{"q": {query}}.Encode()))
+}
+
+// QueryAll adds a SOQL query subrequest that includes deleted and archived
+// records, the result decodes into a SoqlResponse
+func (b *CompositeBatchBuilder) QueryAll(query string) *CompositeBatchBuilder {
+	return b.Get(fmt.Sprintf("queryAll?%s", url.Values{"q": {query}}.Encode()))
+}
+
+// DescribeObject adds a describe subrequest, the result decodes into a
+// DescribeObjectResponse
+func (b *CompositeBatchBuilder) DescribeObject(typeName string) *CompositeBatchBuilder {
+	return b.Get(fmt.Sprintf("sobjects/%s/describe", typeName))
+}
+
+// Limits adds a limits subrequest, the result decodes into a LimitsResponse
+func (b *CompositeBatchBuilder) Limits() *CompositeBatchBuilder {
+	return b.Get("limits")
+}
+
+// CreateObject adds a subrequest that creates an object, the result decodes
+// into an ObjectResponse
+func (b *CompositeBatchBuilder) CreateObject(typeName string, body []byte) *CompositeBatchBuilder {
+	return b.Post(fmt.Sprintf("sobjects/%s", typeName), body)
+}
+
+// UpdateObject adds a subrequest that updates an object by id
+func (b *CompositeBatchBuilder) UpdateObject(typeName string, id string, body []byte) *CompositeBatchBuilder {
+	return b.Patch(fmt.Sprintf("sobjects/%s/%s", typeName, id), body)
+}
+
+// DeleteObject adds a subrequest that deletes an object by id
+func (b *CompositeBatchBuilder) DeleteObject(typeName string, id string) *CompositeBatchBuilder {
+	return b.Delete(fmt.Sprintf("sobjects/%s/%s", typeName, id))
+}
+
+// Build returns the batch request, or the first error from building it
+func (b *CompositeBatchBuilder) Build() (CompositeBatchRequest, error) {
+	if b.err != nil {
+		return CompositeBatchRequest{}, b.err
+	}
+	return b.request, nil
+}
+
+// Execute builds the batch request and sends it
+func (b *CompositeBatchBuilder) Execute() (CompositeBatchResponse, error) {
+	request, err := b.Build()
+	if err != nil {
+		return CompositeBatchResponse{}, err
+	}
+	return b.s.ExecuteCompositeBatchRequest(request)
+}
+
+// versionedPath gets the batch url for a path relative to the versioned data
+// endpoint
+func (b *CompositeBatchBuilder) versionedPath(path string) string {
+	return fmt.Sprintf("v%s/%s", b.s.Config.ApiVersion, strings.TrimPrefix(path, "/"))
+}
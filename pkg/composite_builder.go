@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/joomcode/errorx"
+)
+
+// compositeReferenceIdPattern matches valid reference ids, which salesforce
+// limits to alphanumerics and underscores starting with a letter
+var compositeReferenceIdPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// compositeReferencePattern matches references to earlier subrequests, such
+// as @{NewAccount.id} or @{Query.records[0].Id}, capturing the reference id
+var compositeReferencePattern = regexp.MustCompile(`@\{([^.\[}]+)`)
+
+// compositeReferenceTokenPattern matches a whole reference, such as
+// @{Query.records[0].Id}
+var compositeReferenceTokenPattern = regexp.MustCompile(`@\{[^}]*\}`)
+
+// CompositeRequestBuilder builds a composite request out of arbitrary
+// subrequests. subrequests can use the results of earlier subrequests with
+// the @{referenceId.field} syntax, and the builder checks that every
+// reference points at a subrequest added before it.
+//
+// the builder methods can be chained, the first error is kept and returned
+// by Build or Execute.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_composite.htm
+type CompositeRequestBuilder struct {
+	s            *SalesforceUtils
+	request      CompositeRequest
+	referenceIds map[string]bool
+	err          error
+}
+
+// NewCompositeRequestBuilder creates an empty CompositeRequestBuilder. when
+// allOrNone is true every subrequest is rolled back if any of them fails.
+func (s *SalesforceUtils) NewCompositeRequestBuilder(allOrNone bool) *CompositeRequestBuilder {
+	request := newCompositeRequest()
+	request.AllOrNone = allOrNone
+	return &CompositeRequestBuilder{
+		s:            s,
+		request:      request,
+		referenceIds: map[string]bool{},
+	}
+}
+
+// Add adds a subrequest as is. the url must be a full path starting with
+// /services/data.
+func (b *CompositeRequestBuilder) Add(subRequest CompositeSubRequest) *CompositeRequestBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.err = b.validate(subRequest)
+	if b.err != nil {
+		return b
+	}
+	b.referenceIds[subRequest.ReferenceId] = true
+	b.request.CompositeRequest = append(b.request.CompositeRequest, subRequest)
+	return b
+}
+
+// Get adds a GET subrequest for a path relative to the data endpoint, e.g.
+// "sobjects/Account/@{NewAccount.id}"
+func (b *CompositeRequestBuilder) Get(referenceId string, path string) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodGet, ReferenceId: referenceId, Url: b.dataPath(path)})
+}
+
+// Post adds a POST subrequest for a path relative to the data endpoint
+func (b *CompositeRequestBuilder) Post(referenceId string, path string, body []byte) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodPost, ReferenceId: referenceId, Url: b.dataPath(path), Body: body})
+}
+
+// Patch adds a PATCH subrequest for a path relative to the data endpoint
+func (b *CompositeRequestBuilder) Patch(referenceId string, path string, body []byte) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodPatch, ReferenceId: referenceId, Url: b.dataPath(path), Body: body})
+}
+
+// Delete adds a DELETE subrequest for a path relative to the data endpoint
+func (b *CompositeRequestBuilder) Delete(referenceId string, path string) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodDelete, ReferenceId: referenceId, Url: b.dataPath(path)})
+}
+
+// Query adds a SOQL query subrequest. the query can reference earlier
+// subrequests, e.g. "SELECT Id FROM Contact WHERE AccountId = '@{NewAccount.id}'"
+func (b *CompositeRequestBuilder) Query(referenceId string, query string) *CompositeRequestBuilder {
+	return b.Get(referenceId, fmt.Sprintf("query?q=%s", escapeCompositeQuery(query)))
+}
+
+// escapeCompositeQuery query escapes value except for its references, which
+// salesforce only resolves when they are sent as is
+func escapeCompositeQuery(value string) string {
+	var escaped strings.Builder
+	last := 0
+	for _, match := range compositeReferenceTokenPattern.FindAllStringIndex(value, -1) {
+		escaped.WriteString(url.QueryEscape(value[last:match[0]]))
+		escaped.WriteString(value[match[0]:match[1]])
+		last = match[1]
+	}
+	escaped.WriteString(url.QueryEscape(value[last:]))
+	return escaped.String()
+}
+
+// CreateObject adds a subrequest that creates an object of typeName
+func (b *CompositeRequestBuilder) CreateObject(referenceId string, typeName string, body []byte) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodPost, ReferenceId: referenceId, Url: b.s.getTypePath(typeName), Body: body})
+}
+
+// UpdateObject adds a subrequest that updates an object by id. the id can be
+// a reference, e.g. "@{NewAccount.id}".
+func (b *CompositeRequestBuilder) UpdateObject(referenceId string, typeName string, id string, body []byte) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodPatch, ReferenceId: referenceId, Url: b.s.getObjectIdPath(typeName, id), Body: body})
+}
+
+// DeleteObject adds a subrequest that deletes an object by id
+func (b *CompositeRequestBuilder) DeleteObject(referenceId string, typeName string, id string) *CompositeRequestBuilder {
+	return b.Add(CompositeSubRequest{Method: http.MethodDelete, ReferenceId: referenceId, Url: b.s.getObjectIdPath(typeName, id)})
+}
+
+// Build returns the composite request, or the first error from building it
+func (b *CompositeRequestBuilder) Build() (CompositeRequest, error) {
+	if b.err != nil {
+		return CompositeRequest{}, b.err
+	}
+	if len(b.request.CompositeRequest) == 0 {
+		return CompositeRequest{}, errorx.IllegalArgument.New("composite request has no subrequests")
+	}
+	return b.request, nil
+}
+
+// Execute builds the composite request and sends it
+func (b *CompositeRequestBuilder) Execute() (CompositeResponse, error) {
+	request, err := b.Build()
+	if err != nil {
+		return CompositeResponse{}, err
+	}
+	return b.s.ExecuteCompositeRequest(request)
+}
+
+// ExecuteCompositeRequest sends a composite request, e.g. one built with
+// CompositeRequestBuilder
+func (s *SalesforceUtils) ExecuteCompositeRequest(compositeRequest CompositeRequest) (CompositeResponse, error) {
+	return s.doCompositeRequest(compositeRequest)
+}
+
+// validate checks the reference id of a subrequest and that everything it
+// references was added before it
+func (b *CompositeRequestBuilder) validate(subRequest CompositeSubRequest) error {
+	if !compositeReferenceIdPattern.MatchString(subRequest.ReferenceId) {
+		return errorx.IllegalArgument.New("invalid reference id %q, it must start with a letter and only contain alphanumerics and underscores", subRequest.ReferenceId)
+	}
+	if b.referenceIds[subRequest.ReferenceId] {
+		return errorx.IllegalArgument.New("duplicate reference id %q", subRequest.ReferenceId)
+	}
+	if !strings.HasPrefix(subRequest.Url, "/services/data/") {
+		return errorx.IllegalArgument.New("subrequest %s url must start with /services/data/, got %s", subRequest.ReferenceId, subRequest.Url)
+	}
+	references := []string{subRequest.Url, string(subRequest.Body)}
+	if subRequest.HttpHeaders != nil {
+		headers, err := json.Marshal(subRequest.HttpHeaders)
+		if err != nil {
+			return errorx.Decorate(err, "failed to marshal headers of subrequest %s", subRequest.ReferenceId)
+		}
+		references = append(references, string(headers))
+	}
+	for _, text := range references {
+		for _, match := range compositeReferencePattern.FindAllStringSubmatch(text, -1) {
+			if !b.referenceIds[match[1]] {
+				return errorx.IllegalArgument.New("subrequest %s references %q, which isn't an earlier subrequest", subRequest.ReferenceId, match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// dataPath gets the full path for a path relative to the data endpoint
+func (b *CompositeRequestBuilder) dataPath(path string) string {
+	return fmt.Sprintf("/services/data/v%s/%s", b.s.Config.ApiVersion, strings.TrimPrefix(path, "/"))
+}
+
+// SubResponse gets the sub response for a reference id
+func (c CompositeResponse) SubResponse(referenceId string) (CompositeSubResponse, bool) {
+	for _, subResponse := range c.CompositeResponse {
+		if subResponse.ReferenceId == referenceId {
+			return subResponse, true
+		}
+	}
+	return CompositeSubResponse{}, false
+}
+
+// DecodeSubResponse decodes the body of the sub response for a reference id
+// into v
+func (c CompositeResponse) DecodeSubResponse(referenceId string, v interface{}) error {
+	subResponse, ok := c.SubResponse(referenceId)
+	if !ok {
+		return errorx.IllegalArgument.New("no sub response for reference id %q", referenceId)
+	}
+	return subResponse.DecodeBody(v)
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import "github.com/joomcode/errorx"
+
+// CompositeCreateObjectsChunked is the same as CompositeCreateObjects but
+// accepts any number of objects. the objects are split into requests of 25
+// subrequests and up to concurrency requests are sent at the same time.
+//
+// each request is still allOrNone, but atomicity is lost across requests: a
+// failure rolls back only the objects in its own chunk while every other
+// chunk is committed. the sub responses are returned in input order; the sub
+// responses of a chunk that failed before salesforce answered are left as
+// zero values. if any chunk fails or is rolled back a *ChunkedRequestError is
+// returned that lists the failed input ranges, so they can be retried.
+//
+// @{referenceId} references only resolve within a chunk, so an object that
+// references an object in another chunk is an error and nothing is sent.
+func (s *SalesforceUtils) CompositeCreateObjectsChunked(objects []CompositeObject, concurrency int) (CompositeResponse, error) {
+	return doCompositeChunks(objects, concurrency, func(start, end int) (CompositeResponse, error) {
+		return s.CompositeCreateObjects(objects[start:end])
+	})
+}
+
+// CompositeUpdateObjectsChunked is the same as CompositeUpdateObjects but
+// accepts any number of objects. see CompositeCreateObjectsChunked for how
+// chunks and failures are handled.
+func (s *SalesforceUtils) CompositeUpdateObjectsChunked(objects []CompositeObject, concurrency int) (CompositeResponse, error) {
+	return doCompositeChunks(objects, concurrency, func(start, end int) (CompositeResponse, error) {
+		return s.CompositeUpdateObjects(objects[start:end])
+	})
+}
+
+// CompositeUpsertObjectsChunked is the same as CompositeUpsertObjects but
+// accepts any number of objects. see CompositeCreateObjectsChunked for how
+// chunks and failures are handled.
+func (s *SalesforceUtils) CompositeUpsertObjectsChunked(objects []CompositeObject, concurrency int) (CompositeResponse, error) {
+	return doCompositeChunks(objects, concurrency, func(start, end int) (CompositeResponse, error) {
+		return s.CompositeUpsertObjects(objects[start:end])
+	})
+}
+
+// CompositeDeleteObjectsChunked is the same as CompositeDeleteObjects but
+// accepts any number of objects. see CompositeCreateObjectsChunked for how
+// chunks and failures are handled.
+func (s *SalesforceUtils) CompositeDeleteObjectsChunked(objects []CompositeObject, concurrency int) (CompositeResponse, error) {
+	return doCompositeChunks(objects, concurrency, func(start, end int) (CompositeResponse, error) {
+		return s.CompositeDeleteObjects(objects[start:end])
+	})
+}
+
+// doCompositeChunks splits the objects into chunks of 25, calls request for
+// each chunk with bounded concurrency and places the sub responses of each
+// chunk at the chunk's position in the input. a chunk that was rolled back
+// counts as failed, with the root cause as its error.
+func doCompositeChunks(objects []CompositeObject, concurrency int, request func(start, end int) (CompositeResponse, error)) (CompositeResponse, error) {
+	chunks := chunkRanges(len(objects), compositeMaxSubrequests)
+	err := checkCompositeChunkReferences(objects, chunks)
+	if err != nil {
+		return CompositeResponse{}, err
+	}
+	merged := CompositeResponse{CompositeResponse: make([]CompositeSubResponse, len(objects))}
+	errs := make([]error, len(chunks))
+	forEachConcurrently(len(chunks), concurrency, func(i int) {
+		chunk := chunks[i]
+		response, err := request(chunk.start, chunk.end)
+		copy(merged.CompositeResponse[chunk.start:chunk.end], response.CompositeResponse)
+		if err == nil {
+			err = response.RootCauseError()
+		}
+		errs[i] = err
+	})
+	return merged, newChunkedRequestError(chunks, errs)
+}
+
+// checkCompositeChunkReferences checks that every @{referenceId} reference
+// in an object's id or body points at an object in the same chunk
+func checkCompositeChunkReferences(objects []CompositeObject, chunks []chunkRange) error {
+	chunkOf := map[string]int{}
+	for i, chunk := range chunks {
+		for _, object := range objects[chunk.start:chunk.end] {
+			if object.ReferenceId != "" {
+				chunkOf[object.ReferenceId] = i
+			}
+		}
+	}
+	for i, chunk := range chunks {
+		for j, object := range objects[chunk.start:chunk.end] {
+			references := compositeReferencePattern.FindAllStringSubmatch(object.SalesforceId, -1)
+			references = append(references, compositeReferencePattern.FindAllStringSubmatch(string(object.Body), -1)...)
+			for _, reference := range references {
+				referencedChunk, ok := chunkOf[reference[1]]
+				if ok && referencedChunk != i {
+					return errorx.IllegalArgument.New("object %d references %s in another chunk of %d objects", chunk.start+j, reference[1], compositeMaxSubrequests)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// compositeGraphMaxNodes is the maximum number of subrequests salesforce
+// accepts in a single graph
+const compositeGraphMaxNodes = 500
+
+// CompositeGraph is a single graph of a composite graph request. each graph
+// is its own transaction, a failing node rolls back only its own graph.
+type CompositeGraph struct {
+	GraphId          string                `json:"graphId"`
+	CompositeRequest []CompositeSubRequest `json:"compositeRequest"`
+}
+
+// CompositeGraphRequest is the body of a composite graph request
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_graph.htm
+type CompositeGraphRequest struct {
+	Graphs []CompositeGraph `json:"graphs"`
+}
+
+// CompositeGraphResponse is the response from a composite graph request
+type CompositeGraphResponse struct {
+	Graphs []CompositeGraphResult `json:"graphs"`
+}
+
+// CompositeGraphResult is the outcome of a single graph
+type CompositeGraphResult struct {
+	GraphId       string            `json:"graphId"`
+	GraphResponse CompositeResponse `json:"graphResponse"`
+	IsSuccessful  bool              `json:"isSuccessful"`
+}
+
+// Graph gets the result of a graph by its id
+func (r CompositeGraphResponse) Graph(graphId string) (CompositeGraphResult, bool) {
+	for _, graph := range r.Graphs {
+		if graph.GraphId == graphId {
+			return graph, true
+		}
+	}
+	return CompositeGraphResult{}, false
+}
+
+// FailedGraphs gets the results of the graphs that were rolled back
+func (r CompositeGraphResponse) FailedGraphs() []CompositeGraphResult {
+	var failed []CompositeGraphResult
+	for _, graph := range r.Graphs {
+		if !graph.IsSuccessful {
+			failed = append(failed, graph)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing why the graph was rolled back, or nil if it
+// was successful. the error names the node that caused the rollback.
+func (r CompositeGraphResult) Err() error {
+	if r.IsSuccessful {
+		return nil
+	}
+	err := r.GraphResponse.RootCauseError()
+	if err == nil {
+		return errorx.IllegalState.New("graph %s failed", r.GraphId)
+	}
+	return errorx.Decorate(err, "graph %s failed", r.GraphId)
+}
+
+// ExecuteCompositeGraphs sends one or more graphs in a single composite graph
+// request. graphs succeed or fail independently, check IsSuccessful or Err on
+// each result.
+func (s *SalesforceUtils) ExecuteCompositeGraphs(graphs ...CompositeGraph) (response CompositeGraphResponse, err error) {
+	err = validateCompositeGraphs(graphs)
+	if err != nil {
+		return
+	}
+	reqBodyBytes, err := json.Marshal(CompositeGraphRequest{Graphs: graphs})
+	if err != nil {
+		return
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getCompositeGraphUrl())
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(reqBodyBytes)
+
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return response, err
+	}
+	if statusCode != fasthttp.StatusOK {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, errorx.IllegalState.New("failed to unmarshal response: %s", err)
+	}
+	return response, nil
+}
+
+// validateCompositeGraphs checks the graph ids and node counts against the
+// limits of the composite graph api
+func validateCompositeGraphs(graphs []CompositeGraph) error {
+	if len(graphs) == 0 {
+		return errorx.IllegalArgument.New("input must not be empty")
+	}
+	graphIds := map[string]bool{}
+	for _, graph := range graphs {
+		if graph.GraphId == "" {
+			return errorx.IllegalArgument.New("graph id must not be empty")
+		}
+		if graphIds[graph.GraphId] {
+			return errorx.IllegalArgument.New("duplicate graph id %q", graph.GraphId)
+		}
+		graphIds[graph.GraphId] = true
+		if len(graph.CompositeRequest) == 0 {
+			return errorx.IllegalArgument.New("graph %s has no nodes", graph.GraphId)
+		}
+		if len(graph.CompositeRequest) > compositeGraphMaxNodes {
+			return errorx.IllegalArgument.New("graph %s has %d nodes, must not be larger than %d", graph.GraphId, len(graph.CompositeRequest), compositeGraphMaxNodes)
+		}
+	}
+	return nil
+}
+
+func (s *SalesforceUtils) getCompositeGraphUrl() string {
+	return fmt.Sprintf("%s/graph", s.getCompositeUrl())
+}
+
+// CompositeGraphNode is a node added to a CompositeGraphBuilder, used to refer
+// to the node's result from later nodes
+type CompositeGraphNode struct {
+	ReferenceId string
+}
+
+// Ref gets a reference to a field of the node's result, e.g. Ref("id")
+func (n CompositeGraphNode) Ref(field string) string {
+	return fmt.Sprintf("@{%s.%s}", n.ReferenceId, field)
+}
+
+// IdRef gets a reference to the id of the record the node created
+func (n CompositeGraphNode) IdRef() string {
+	return n.Ref("id")
+}
+
+// CompositeGraphBuilder builds a single graph out of parent and child
+// records. reference ids are generated for every node, and children are
+// linked to their parent by setting the parent's id on a field of the child.
+// the first error is kept and returned by Build.
+type CompositeGraphBuilder struct {
+	graphId   string
+	builder   *CompositeRequestBuilder
+	nodeCount int
+}
+
+// NewCompositeGraphBuilder creates an empty CompositeGraphBuilder
+func (s *SalesforceUtils) NewCompositeGraphBuilder(graphId string) *CompositeGraphBuilder {
+	return &CompositeGraphBuilder{
+		graphId: graphId,
+		builder: s.NewCompositeRequestBuilder(true),
+	}
+}
+
+// Create adds a node that creates a record with no parent
+func (g *CompositeGraphBuilder) Create(typeName string, body []byte) CompositeGraphNode {
+	node := g.nextNode(typeName)
+	g.builder.CreateObject(node.ReferenceId, typeName, body)
+	return node
+}
+
+// CreateChild adds a node that creates a record linked to parent, by setting
+// parentField of the record to the parent's id
+func (g *CompositeGraphBuilder) CreateChild(parent CompositeGraphNode, typeName string, parentField string, body []byte) CompositeGraphNode {
+	node := g.nextNode(typeName)
+	body, err := setJsonField(body, parentField, parent.IdRef())
+	if err != nil && g.builder.err == nil {
+		g.builder.err = errorx.Decorate(err, "failed to set %s on %s", parentField, node.ReferenceId)
+	}
+	g.builder.CreateObject(node.ReferenceId, typeName, body)
+	return node
+}
+
+// Update adds a node that updates an existing record. the id can be a
+// reference to another node, e.g. node.IdRef().
+func (g *CompositeGraphBuilder) Update(typeName string, id string, body []byte) CompositeGraphNode {
+	node := g.nextNode(typeName)
+	g.builder.UpdateObject(node.ReferenceId, typeName, id, body)
+	return node
+}
+
+// Add adds an arbitrary subrequest as a node. the reference id is generated,
+// any reference id set on subRequest is replaced.
+func (g *CompositeGraphBuilder) Add(subRequest CompositeSubRequest) CompositeGraphNode {
+	node := g.nextNode("Node")
+	subRequest.ReferenceId = node.ReferenceId
+	g.builder.Add(subRequest)
+	return node
+}
+
+// Build returns the graph, or the first error from building it
+func (g *CompositeGraphBuilder) Build() (CompositeGraph, error) {
+	request, err := g.builder.Build()
+	if err != nil {
+		return CompositeGraph{}, errorx.Decorate(err, "failed to build graph %s", g.graphId)
+	}
+	graph := CompositeGraph{GraphId: g.graphId, CompositeRequest: request.CompositeRequest}
+	return graph, validateCompositeGraphs([]CompositeGraph{graph})
+}
+
+func (g *CompositeGraphBuilder) nextNode(typeName string) CompositeGraphNode {
+	g.nodeCount++
+	return CompositeGraphNode{ReferenceId: fmt.Sprintf("%s_%d", typeName, g.nodeCount)}
+}
+
+// setJsonField sets a top level field on a json object
+func setJsonField(body []byte, field string, value interface{}) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if len(body) > 0 {
+		err := json.Unmarshal(body, &fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields[field] = valueJson
+	return json.Marshal(fields)
+}
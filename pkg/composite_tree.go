@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// limits of the sObject tree api for a single request
+const (
+	sObjectTreeMaxRecords = 200
+	sObjectTreeMaxDepth   = 5
+	sObjectTreeMaxTypes   = 5
+)
+
+// SObjectTreeRecord is a record of an sObject tree along with its nested
+// child records
+type SObjectTreeRecord struct {
+	// Type is the salesforce object type, e.g. "Contact". it can be left
+	// empty on root records.
+	Type string
+	// ReferenceId identifies the record in the results. one is generated
+	// when empty, but only on the copy that is sent, so set it to find the
+	// record's id in the results.
+	ReferenceId string
+	// Fields are the field values of the record
+	Fields map[string]interface{}
+	// Children maps child relationship names, e.g. "Contacts", to the child
+	// records to create under this record
+	Children map[string][]SObjectTreeRecord
+}
+
+// MarshalJSON writes the record in the nested format the tree api expects
+func (r SObjectTreeRecord) MarshalJSON() ([]byte, error) {
+	record := make(map[string]interface{}, len(r.Fields)+len(r.Children)+1)
+	for field, value := range r.Fields {
+		record[field] = value
+	}
+	record["attributes"] = map[string]string{
+		"type":        r.Type,
+		"referenceId": r.ReferenceId,
+	}
+	for relationship, children := range r.Children {
+		record[relationship] = map[string][]SObjectTreeRecord{"records": children}
+	}
+	return json.Marshal(record)
+}
+
+// SObjectTreeResponse is the response from the tree api
+type SObjectTreeResponse struct {
+	HasErrors bool                `json:"hasErrors"`
+	Results   []SObjectTreeResult `json:"results"`
+}
+
+// SObjectTreeResult is the outcome of a single record of a tree. when the
+// request fails only the records that caused the failure are listed.
+type SObjectTreeResult struct {
+	ReferenceId string                     `json:"referenceId"`
+	Id          string                     `json:"id"`
+	Errors      []CollectionsResponseError `json:"errors"`
+}
+
+// CreateObjectTree creates root records of typeName along with their nested
+// child records using the sObject tree api, returning a map of reference ids
+// to the ids of the created records. records are sent as a copy, with a
+// ReferenceId that doesn't collide with any explicit one generated for every
+// record that doesn't have one. the records passed in are left unchanged.
+//
+// salesforce allows 200 records, 5 levels of nesting and 5 object types per
+// request. the root records are split across as many requests as needed, but
+// a single root with its children must fit in one request. each request is
+// its own transaction, so when a request fails the records of earlier
+// requests stay created and are returned along with the error.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_sobject_tree.htm
+func (s *SalesforceUtils) CreateObjectTree(typeName string, records []SObjectTreeRecord) (map[string]string, error) {
+	if len(records) == 0 {
+		return nil, errorx.IllegalArgument.New("input must not be empty")
+	}
+	records = copySObjectTree(records)
+	referenceIds := map[string]bool{}
+	for i := range records {
+		if records[i].Type == "" {
+			records[i].Type = typeName
+		}
+		if records[i].Type != typeName {
+			return nil, errorx.IllegalArgument.New("root record %d has type %s, expected %s", i, records[i].Type, typeName)
+		}
+		err := checkSObjectTreeRecord(records[i], 1, referenceIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// ids are generated once every explicit one is known, so they can't
+	// collide with one on a later record
+	generated := 0
+	for i := range records {
+		assignSObjectTreeReferenceIds(&records[i], referenceIds, &generated)
+	}
+
+	batches, err := splitSObjectTree(records)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]string{}
+	for _, batch := range batches {
+		response, requestErr := s.doObjectTreeRequest(typeName, batch)
+		for _, result := range response.Results {
+			if result.Id != "" {
+				ids[result.ReferenceId] = result.Id
+			}
+		}
+		if requestErr != nil {
+			return ids, requestErr
+		}
+	}
+	return ids, nil
+}
+
+// copySObjectTree copies records and their children, so that reference ids
+// and types can be filled in without changing the caller's records
+func copySObjectTree(records []SObjectTreeRecord) []SObjectTreeRecord {
+	copied := make([]SObjectTreeRecord, len(records))
+	for i, record := range records {
+		copied[i] = record
+		if record.Children != nil {
+			copied[i].Children = make(map[string][]SObjectTreeRecord, len(record.Children))
+			for relationship, children := range record.Children {
+				copied[i].Children[relationship] = copySObjectTree(children)
+			}
+		}
+	}
+	return copied
+}
+
+// checkSObjectTreeRecord checks the types, depth and explicit reference ids
+// of a record and its children, adding the reference ids to referenceIds
+func checkSObjectTreeRecord(record SObjectTreeRecord, depth int, referenceIds map[string]bool) error {
+	if depth > sObjectTreeMaxDepth {
+		return errorx.IllegalArgument.New("records must not be nested more than %d levels deep", sObjectTreeMaxDepth)
+	}
+	if record.Type == "" {
+		return errorx.IllegalArgument.New("child records must have a type")
+	}
+	if record.ReferenceId != "" {
+		if referenceIds[record.ReferenceId] {
+			return errorx.IllegalArgument.New("duplicate reference id %q", record.ReferenceId)
+		}
+		referenceIds[record.ReferenceId] = true
+	}
+	for _, children := range record.Children {
+		for _, child := range children {
+			err := checkSObjectTreeRecord(child, depth+1, referenceIds)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// assignSObjectTreeReferenceIds generates a reference id that isn't in
+// referenceIds for a record and its children that don't have one
+func assignSObjectTreeReferenceIds(record *SObjectTreeRecord, referenceIds map[string]bool, generated *int) {
+	if record.ReferenceId == "" {
+		for record.ReferenceId == "" || referenceIds[record.ReferenceId] {
+			*generated++
+			record.ReferenceId = fmt.Sprintf("ref%d", *generated)
+		}
+		referenceIds[record.ReferenceId] = true
+	}
+	for _, children := range record.Children {
+		for i := range children {
+			assignSObjectTreeReferenceIds(&children[i], referenceIds, generated)
+		}
+	}
+}
+
+// splitSObjectTree groups root records into batches that fit the record and
+// type limits of a single request
+func splitSObjectTree(records []SObjectTreeRecord) ([][]SObjectTreeRecord, error) {
+	var batches [][]SObjectTreeRecord
+	var batch []SObjectTreeRecord
+	batchCount := 0
+	batchTypes := map[string]bool{}
+	for i, record := range records {
+		types := map[string]bool{}
+		count := countSObjectTree(record, types)
+		if count > sObjectTreeMaxRecords {
+			return nil, errorx.IllegalArgument.New("root record %d has %d records, must not be larger than %d", i, count, sObjectTreeMaxRecords)
+		}
+		if len(types) > sObjectTreeMaxTypes {
+			return nil, errorx.IllegalArgument.New("root record %d has %d object types, must not be more than %d", i, len(types), sObjectTreeMaxTypes)
+		}
+		mergedTypes := len(batchTypes)
+		for recordType := range types {
+			if !batchTypes[recordType] {
+				mergedTypes++
+			}
+		}
+		if len(batch) > 0 && (batchCount+count > sObjectTreeMaxRecords || mergedTypes > sObjectTreeMaxTypes) {
+			batches = append(batches, batch)
+			batch = nil
+			batchCount = 0
+			batchTypes = map[string]bool{}
+		}
+		batch = append(batch, record)
+		batchCount += count
+		for recordType := range types {
+			batchTypes[recordType] = true
+		}
+	}
+	return append(batches, batch), nil
+}
+
+// countSObjectTree counts a record and its children, collecting their types
+func countSObjectTree(record SObjectTreeRecord, types map[string]bool) int {
+	types[record.Type] = true
+	count := 1
+	for _, children := range record.Children {
+		for _, child := range children {
+			count += countSObjectTree(child, types)
+		}
+	}
+	return count
+}
+
+func (s *SalesforceUtils) doObjectTreeRequest(typeName string, records []SObjectTreeRecord) (response SObjectTreeResponse, err error) {
+	reqBodyBytes, err := json.Marshal(map[string][]SObjectTreeRecord{"records": records})
+	if err != nil {
+		return response, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getCompositeTreeUrl(typeName))
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(reqBodyBytes)
+
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return response, err
+	}
+	if statusCode != http.StatusCreated && statusCode != http.StatusBadRequest {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return response, errorx.IllegalState.New("failed to unmarshal response with status code: %d and body: %s", statusCode, body)
+	}
+	if response.HasErrors || statusCode != http.StatusCreated {
+		for _, result := range response.Results {
+			if len(result.Errors) > 0 {
+				return response, errorx.IllegalState.New("failed to create record %s: %s", result.ReferenceId, result.Errors)
+			}
+		}
+		return response, errorx.IllegalState.New("failed to create tree with body: %s", body)
+	}
+	return response, nil
+}
+
+// getCompositeTreeUrl gets a formatted full url to the tree api for a type
+func (s *SalesforceUtils) getCompositeTreeUrl(typeName string) string {
+	return fmt.Sprintf("%s/tree/%s", s.getCompositeUrl(), typeName)
+}
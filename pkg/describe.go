@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// SObjectDescribe is the complete response from the "sObject Describe" API
+// call. DescribeObjectResponse is a slimmed down version of it.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_sobject_describe.htm
+type SObjectDescribe struct {
+	Name                string              `json:"name"`
+	Label               string              `json:"label"`
+	LabelPlural         string              `json:"labelPlural"`
+	KeyPrefix           string              `json:"keyPrefix"`
+	Custom              bool                `json:"custom"`
+	CustomSetting       bool                `json:"customSetting"`
+	Activateable        bool                `json:"activateable"`
+	Createable          bool                `json:"createable"`
+	Deletable           bool                `json:"deletable"`
+	DeprecatedAndHidden bool                `json:"deprecatedAndHidden"`
+	FeedEnabled         bool                `json:"feedEnabled"`
+	Layoutable          bool                `json:"layoutable"`
+	Mergeable           bool                `json:"mergeable"`
+	MruEnabled          bool                `json:"mruEnabled"`
+	Queryable           bool                `json:"queryable"`
+	Replicateable       bool                `json:"replicateable"`
+	Retrieveable        bool                `json:"retrieveable"`
+	Searchable          bool                `json:"searchable"`
+	Triggerable         bool                `json:"triggerable"`
+	Undeletable         bool                `json:"undeletable"`
+	Updateable          bool                `json:"updateable"`
+	Fields              []DescribeField     `json:"fields"`
+	ChildRelationships  []ChildRelationship `json:"childRelationships"`
+	RecordTypeInfos     []RecordTypeInfo    `json:"recordTypeInfos"`
+	SupportedScopes     []ScopeInfo         `json:"supportedScopes"`
+	// Urls maps resource names, e.g. "describe" or "rowTemplate", to their
+	// paths
+	Urls map[string]string `json:"urls"`
+}
+
+// DescribeField is a single field of an sObject describe
+type DescribeField struct {
+	Name                string `json:"name"`
+	Label               string `json:"label"`
+	Type                string `json:"type"`
+	SoapType            string `json:"soapType"`
+	ExtraTypeInfo       string `json:"extraTypeInfo"`
+	Length              int    `json:"length"`
+	ByteLength          int    `json:"byteLength"`
+	Digits              int    `json:"digits"`
+	Precision           int    `json:"precision"`
+	Scale               int    `json:"scale"`
+	AutoNumber          bool   `json:"autoNumber"`
+	Calculated          bool   `json:"calculated"`
+	CalculatedFormula   string `json:"calculatedFormula"`
+	CaseSensitive       bool   `json:"caseSensitive"`
+	Createable          bool   `json:"createable"`
+	Updateable          bool   `json:"updateable"`
+	Custom              bool   `json:"custom"`
+	DefaultedOnCreate   bool   `json:"defaultedOnCreate"`
+	DefaultValueFormula string `json:"defaultValueFormula"`
+	DeprecatedAndHidden bool   `json:"deprecatedAndHidden"`
+	Encrypted           bool   `json:"encrypted"`
+	ExternalId          bool   `json:"externalId"`
+	Filterable          bool   `json:"filterable"`
+	Groupable           bool   `json:"groupable"`
+	HtmlFormatted       bool   `json:"htmlFormatted"`
+	IdLookup            bool   `json:"idLookup"`
+	InlineHelpText      string `json:"inlineHelpText"`
+	NameField           bool   `json:"nameField"`
+	Nillable            bool   `json:"nillable"`
+	Sortable            bool   `json:"sortable"`
+	Unique              bool   `json:"unique"`
+	// DefaultValue can be of any json type, depending on the field type
+	DefaultValue            interface{}     `json:"defaultValue"`
+	PicklistValues          []PicklistEntry `json:"picklistValues"`
+	RestrictedPicklist      bool            `json:"restrictedPicklist"`
+	DependentPicklist       bool            `json:"dependentPicklist"`
+	ControllerName          string          `json:"controllerName"`
+	ReferenceTo             []string        `json:"referenceTo"`
+	RelationshipName        string          `json:"relationshipName"`
+	RelationshipOrder       *int            `json:"relationshipOrder"`
+	CascadeDelete           bool            `json:"cascadeDelete"`
+	RestrictedDelete        bool            `json:"restrictedDelete"`
+	PolymorphicForeignKey   bool            `json:"polymorphicForeignKey"`
+	WriteRequiresMasterRead bool            `json:"writeRequiresMasterRead"`
+}
+
+// PicklistEntry is a single value of a picklist field
+type PicklistEntry struct {
+	Value        string `json:"value"`
+	Label        string `json:"label"`
+	Active       bool   `json:"active"`
+	DefaultValue bool   `json:"defaultValue"`
+	// ValidFor is a base64 encoded bitmap of the controlling field's values
+	// this value is valid for, only set on dependent picklists. use
+	// IsValidFor to decode it.
+	ValidFor string `json:"validFor"`
+}
+
+// ChildRelationship is a relationship from another object that looks up to
+// the described object
+type ChildRelationship struct {
+	ChildSObject        string `json:"childSObject"`
+	Field               string `json:"field"`
+	RelationshipName    string `json:"relationshipName"`
+	CascadeDelete       bool   `json:"cascadeDelete"`
+	RestrictedDelete    bool   `json:"restrictedDelete"`
+	DeprecatedAndHidden bool   `json:"deprecatedAndHidden"`
+}
+
+// RecordTypeInfo is a record type available for the described object
+type RecordTypeInfo struct {
+	RecordTypeId             string            `json:"recordTypeId"`
+	Name                     string            `json:"name"`
+	DeveloperName            string            `json:"developerName"`
+	Active                   bool              `json:"active"`
+	Available                bool              `json:"available"`
+	DefaultRecordTypeMapping bool              `json:"defaultRecordTypeMapping"`
+	Master                   bool              `json:"master"`
+	Urls                     map[string]string `json:"urls"`
+}
+
+// ScopeInfo is a scope that can be used in a SOQL USING SCOPE clause
+type ScopeInfo struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// DescribeObjectFull describes the object type, returning the complete
+// describe including picklist values, relationships and record types
+func (s *SalesforceUtils) DescribeObjectFull(typeName string) (response SObjectDescribe, err error) {
+	err = s.doDescribeRequest(typeName, &response)
+	return
+}
+
+// Field gets a field of the describe by name
+func (d SObjectDescribe) Field(name string) (DescribeField, bool) {
+	for _, field := range d.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return DescribeField{}, false
+}
+
+// ExternalIdFields gets the fields that are marked as external ids
+func (d SObjectDescribe) ExternalIdFields() []DescribeField {
+	var fields []DescribeField
+	for _, field := range d.Fields {
+		if field.ExternalId {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// IsValidFor reports whether the entry is valid for the controlling value at
+// index, where index is the position of the value in the controlling field's
+// picklist values, or 0 for false and 1 for true when the controlling field
+// is a checkbox. bit i of the bitmap is read most significant bit first.
+func (p PicklistEntry) IsValidFor(index int) (bool, error) {
+	bitmap, err := base64.StdEncoding.DecodeString(p.ValidFor)
+	if err != nil {
+		return false, errorx.IllegalFormat.Wrap(err, "invalid validFor bitmap %q", p.ValidFor)
+	}
+	if index < 0 || index/8 >= len(bitmap) {
+		return false, nil
+	}
+	return bitmap[index/8]&(0x80>>(index%8)) != 0, nil
+}
+
+// DependentPicklistValues maps each value of the controlling field of the
+// dependent picklist fieldName to the dependent values that are valid for it
+func (d SObjectDescribe) DependentPicklistValues(fieldName string) (map[string][]PicklistEntry, error) {
+	field, ok := d.Field(fieldName)
+	if !ok {
+		return nil, errorx.IllegalArgument.New("%s has no field %s", d.Name, fieldName)
+	}
+	if !field.DependentPicklist || field.ControllerName == "" {
+		return nil, errorx.IllegalArgument.New("%s.%s is not a dependent picklist", d.Name, fieldName)
+	}
+	controller, ok := d.Field(field.ControllerName)
+	if !ok {
+		return nil, errorx.IllegalState.New("%s has no controlling field %s", d.Name, field.ControllerName)
+	}
+
+	var controllingValues []string
+	if controller.Type == "boolean" {
+		controllingValues = []string{"false", "true"}
+	} else {
+		for _, entry := range controller.PicklistValues {
+			controllingValues = append(controllingValues, entry.Value)
+		}
+	}
+	dependentValues := make(map[string][]PicklistEntry, len(controllingValues))
+	for _, entry := range field.PicklistValues {
+		for i, controllingValue := range controllingValues {
+			valid, err := entry.IsValidFor(i)
+			if err != nil {
+				return nil, err
+			}
+			if valid {
+				dependentValues[controllingValue] = append(dependentValues[controllingValue], entry)
+			}
+		}
+	}
+	return dependentValues, nil
+}
+
+// doDescribeRequest gets the describe of an object type and unmarshals it
+// into out
+func (s *SalesforceUtils) doDescribeRequest(typeName string, out interface{}) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	uri := s.getDescribeUrl(typeName)
+	req.SetRequestURI(uri)
+	req.Header.SetMethod(http.MethodGet)
+	req.Header.Set("Content-Type", "application/json")
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return err
+	}
+	if statusCode == http.StatusNotFound {
+		return NotFoundError.New("object type %s not found with body: %s", typeName, body)
+	}
+	if statusCode != http.StatusOK {
+		return errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// DescribeGlobalResponse is the response from the "Describe Global" API call,
+// listing every object available in the org
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_describeGlobal.htm
+type DescribeGlobalResponse struct {
+	Encoding     string                  `json:"encoding"`
+	MaxBatchSize int                     `json:"maxBatchSize"`
+	SObjects     []DescribeGlobalSObject `json:"sobjects"`
+}
+
+// DescribeGlobalSObject is the summary of a single object in a global
+// describe. use DescribeObjectFull for the fields of the object.
+type DescribeGlobalSObject struct {
+	Name                string            `json:"name"`
+	Label               string            `json:"label"`
+	LabelPlural         string            `json:"labelPlural"`
+	KeyPrefix           string            `json:"keyPrefix"`
+	Custom              bool              `json:"custom"`
+	CustomSetting       bool              `json:"customSetting"`
+	Createable          bool              `json:"createable"`
+	Deletable           bool              `json:"deletable"`
+	DeprecatedAndHidden bool              `json:"deprecatedAndHidden"`
+	Queryable           bool              `json:"queryable"`
+	Replicateable       bool              `json:"replicateable"`
+	Retrieveable        bool              `json:"retrieveable"`
+	Searchable          bool              `json:"searchable"`
+	Triggerable         bool              `json:"triggerable"`
+	Updateable          bool              `json:"updateable"`
+	Urls                map[string]string `json:"urls"`
+}
+
+// DescribeGlobal lists every object available in the org
+func (s *SalesforceUtils) DescribeGlobal() (response DescribeGlobalResponse, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getDescribeGlobalUrl())
+	req.Header.SetMethod(http.MethodGet)
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return response, err
+	}
+	if statusCode != http.StatusOK {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	err = json.Unmarshal(body, &response)
+	return
+}
+
+// DescribeCache keeps object describes between calls to DescribeObjectCached.
+// it is safe for concurrent use.
+type DescribeCache struct {
+	// MaxAge is how long a cached describe is used without revalidating it.
+	// the default of 0 revalidates on every call, which still saves the
+	// transfer of the describe when it hasn't changed.
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]DescribeCacheEntry
+}
+
+// DescribeCacheEntry is a cached describe of a single object type
+type DescribeCacheEntry struct {
+	Describe SObjectDescribe `json:"describe"`
+	// LastModified is the Last-Modified of the describe, sent as
+	// If-Modified-Since when revalidating. it's zero when salesforce didn't
+	// send one, and the describe is then fetched again in full.
+	LastModified time.Time `json:"lastModified"`
+	// ValidatedAt is when salesforce last confirmed the describe
+	ValidatedAt time.Time `json:"validatedAt"`
+}
+
+// NewDescribeCache creates an empty DescribeCache
+func NewDescribeCache(maxAge time.Duration) *DescribeCache {
+	return &DescribeCache{MaxAge: maxAge, entries: map[string]DescribeCacheEntry{}}
+}
+
+// Get gets the cached describe of an object type, regardless of its age
+func (c *DescribeCache) Get(typeName string) (DescribeCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[typeName]
+	return entry, ok
+}
+
+// Set replaces the cached describe of an object type
+func (c *DescribeCache) Set(typeName string, entry DescribeCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]DescribeCacheEntry{}
+	}
+	c.entries[typeName] = entry
+}
+
+// Invalidate removes the cached describe of an object type, or of every type
+// when no type names are given
+func (c *DescribeCache) Invalidate(typeNames ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(typeNames) == 0 {
+		c.entries = map[string]DescribeCacheEntry{}
+		return
+	}
+	for _, typeName := range typeNames {
+		delete(c.entries, typeName)
+	}
+}
+
+// Save writes the cache to a json file, so it can be loaded by a later run
+func (c *DescribeCache) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return saveJsonFile(path, c.entries)
+}
+
+// Load replaces the cache with the entries of a json file written by Save.
+// a missing file leaves the cache empty.
+func (c *DescribeCache) Load(path string) error {
+	entries, err := loadJsonFile[map[string]DescribeCacheEntry](path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]DescribeCacheEntry{}
+	if entries != nil {
+		c.entries = *entries
+	}
+	return nil
+}
+
+// DescribeObjectCached describes the object type through s.DescribeCache. a
+// cached describe younger than MaxAge is returned as is, an older one is
+// revalidated with If-Modified-Since and only fetched again if it changed.
+// a describe that came without a Last-Modified header is always fetched again
+// once it's older than MaxAge, since the local clock can't stand in for the
+// server's. without a cache this is the same as DescribeObjectFull.
+func (s *SalesforceUtils) DescribeObjectCached(typeName string) (SObjectDescribe, error) {
+	if s.DescribeCache == nil {
+		return s.DescribeObjectFull(typeName)
+	}
+	entry, ok := s.DescribeCache.Get(typeName)
+	if ok && time.Since(entry.ValidatedAt) < s.DescribeCache.MaxAge {
+		return entry.Describe, nil
+	}
+
+	var ifModifiedSince time.Time
+	if ok {
+		ifModifiedSince = entry.LastModified
+	}
+	fetchedAt := time.Now()
+	describe, lastModified, notModified, err := s.doConditionalDescribeRequest(typeName, ifModifiedSince)
+	if err != nil {
+		return SObjectDescribe{}, err
+	}
+	if notModified {
+		entry.ValidatedAt = fetchedAt
+	} else {
+		entry = DescribeCacheEntry{Describe: describe, LastModified: lastModified, ValidatedAt: fetchedAt}
+	}
+	s.DescribeCache.Set(typeName, entry)
+	return entry.Describe, nil
+}
+
+// WarmDescribeCache describes every type in typeNames through
+// DescribeObjectCached, running at most concurrency requests at the same
+// time. every type is attempted, the errors of the types that failed are
+// returned together.
+func (s *SalesforceUtils) WarmDescribeCache(typeNames []string, concurrency int) error {
+	errs := make([]error, len(typeNames))
+	forEachConcurrently(len(typeNames), concurrency, func(i int) {
+		_, err := s.DescribeObjectCached(typeNames[i])
+		if err != nil {
+			errs[i] = errorx.Decorate(err, "failed to describe %s", typeNames[i])
+		}
+	})
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errorx.DecorateMany("failed to warm describe cache", failed...)
+}
+
+// doConditionalDescribeRequest gets the describe of an object type, sending
+// If-Modified-Since when ifModifiedSince is set. notModified is true when
+// salesforce answered 304, in which case describe is empty.
+func (s *SalesforceUtils) doConditionalDescribeRequest(typeName string, ifModifiedSince time.Time) (describe SObjectDescribe, lastModified time.Time, notModified bool, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getDescribeUrl(typeName))
+	req.Header.SetMethod(http.MethodGet)
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	res, deferredFunc, err := s.sendRequestForResponse(req)
+	defer deferredFunc()
+	if err != nil {
+		return
+	}
+	if header := res.Header.Peek("Last-Modified"); len(header) > 0 {
+		lastModified, _ = http.ParseTime(string(header))
+	}
+	switch res.StatusCode() {
+	case http.StatusOK:
+		err = json.Unmarshal(res.Body(), &describe)
+	case http.StatusNotModified:
+		notModified = true
+	case http.StatusNotFound:
+		err = NotFoundError.New("object type %s not found with body: %s", typeName, res.Body())
+	default:
+		err = errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+	}
+	return
+}
+
+// getDescribeGlobalUrl gets a formatted full url to the global describe
+// endpoint
+func (s *SalesforceUtils) getDescribeGlobalUrl() string {
+	return fmt.Sprintf("%s%s", s.Config.BaseUrl, s.getDataPath())
+}
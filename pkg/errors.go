@@ -0,0 +1,34 @@
+package pkg
+
+import "github.com/joomcode/errorx"
+
+// SalesforceErrors is the namespace for errors that callers may want to
+// handle by type rather than by message
+var SalesforceErrors = errorx.NewNamespace("salesforce")
+
+// NotFoundError is returned when the requested record or resource doesn't
+// exist. it has the errorx.NotFound trait, so errorx.IsNotFound works on it.
+var NotFoundError = SalesforceErrors.NewType("not_found", errorx.NotFound())
+
+// ConflictError is returned by conditional updates and deletes when the
+// record changed since the version the caller knew. use ConflictVersion to
+// get the record's current version.
+var ConflictError = SalesforceErrors.NewType("conflict")
+
+// conflictVersionProperty holds the current ObjectVersion of a ConflictError
+var conflictVersionProperty = errorx.RegisterProperty("conflict_version")
+
+// ConflictVersion gets the current version of the record from a
+// ConflictError. ok is false if err isn't a ConflictError or salesforce sent
+// neither an ETag nor a Last-Modified header.
+func ConflictVersion(err error) (version ObjectVersion, ok bool) {
+	if !errorx.IsOfType(err, ConflictError) {
+		return
+	}
+	property, ok := errorx.Cast(err).Property(conflictVersionProperty)
+	if !ok {
+		return
+	}
+	version, ok = property.(ObjectVersion)
+	return
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"sort"
+
+	"github.com/joomcode/errorx"
+)
+
+// limits of the merge, undelete and emptyRecycleBin calls
+const (
+	soapMaxRecords        = 200
+	mergeMaxDuplicates    = 2
+	mergeMaxMergeRequests = 200
+)
+
+// RecordOperationResult is the outcome of undeleting or purging a single
+// record
+type RecordOperationResult struct {
+	Id      string                     `xml:"id"`
+	Success bool                       `xml:"success"`
+	Errors  []CollectionsResponseError `xml:"errors"`
+}
+
+// MergeRequest merges up to two duplicate records into a master record of
+// the same type. Account, Contact, Lead and Case records can be merged.
+type MergeRequest struct {
+	TypeName       string
+	MasterRecordId string
+	DuplicateIds   []string
+	// Fields are set on the master record as part of the merge, use them to
+	// keep a duplicate's value of a field
+	Fields map[string]string
+}
+
+// MergeResult is the outcome of a single MergeRequest
+type MergeResult struct {
+	Id      string                     `xml:"id"`
+	Success bool                       `xml:"success"`
+	Errors  []CollectionsResponseError `xml:"errors"`
+	// MergedRecordIds are the duplicates that were merged and deleted
+	MergedRecordIds []string `xml:"mergedRecordIds"`
+	// UpdatedRelatedIds are the child records that were reparented to the
+	// master record
+	UpdatedRelatedIds []string `xml:"updatedRelatedIds"`
+}
+
+// MergeRecords merges duplicate records into their master records using the
+// soap api, returning one result per request in order. a request that fails
+// doesn't affect the others, check Success on every result.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api.meta/api/sforce_api_calls_merge.htm
+func (s *SalesforceUtils) MergeRecords(requests []MergeRequest) ([]MergeResult, error) {
+	if len(requests) == 0 {
+		return nil, errorx.IllegalArgument.New("input must not be empty")
+	}
+	if len(requests) > mergeMaxMergeRequests {
+		return nil, errorx.IllegalArgument.New("input must not be larger than %d", mergeMaxMergeRequests)
+	}
+	call := soapMergeCall{}
+	for i, request := range requests {
+		if request.TypeName == "" || request.MasterRecordId == "" {
+			return nil, errorx.IllegalArgument.New("merge request %d must have a type name and a master record id", i)
+		}
+		if len(request.DuplicateIds) == 0 || len(request.DuplicateIds) > mergeMaxDuplicates {
+			return nil, errorx.IllegalArgument.New("merge request %d must have 1 to %d duplicates, got %d", i, mergeMaxDuplicates, len(request.DuplicateIds))
+		}
+		call.Requests = append(call.Requests, soapMergeRequest{
+			MasterRecord:     soapSObject{TypeName: request.TypeName, Id: request.MasterRecordId, Fields: request.Fields},
+			RecordToMergeIds: request.DuplicateIds,
+		})
+	}
+	response, err := doSoapRequest[struct {
+		Results []MergeResult `xml:"result"`
+	}](s, "merge", call)
+	if err != nil {
+		return nil, err
+	}
+	return response.Results, validateSoapResultsLength(len(requests), len(response.Results))
+}
+
+// UndeleteRecords restores deleted records from the recycle bin, returning
+// one result per id in order. deleted records can be found with
+// ExecuteSoqlQueryAll and "IsDeleted = true".
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api.meta/api/sforce_api_calls_undelete.htm
+func (s *SalesforceUtils) UndeleteRecords(ids []string) ([]RecordOperationResult, error) {
+	return s.doSoapIdsCall("undelete", soapUndeleteCall{Ids: ids})
+}
+
+// EmptyRecycleBin permanently deletes records from the recycle bin, returning
+// one result per id in order. the records can't be undeleted afterwards.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api.meta/api/sforce_api_calls_emptyrecyclebin.htm
+func (s *SalesforceUtils) EmptyRecycleBin(ids []string) ([]RecordOperationResult, error) {
+	return s.doSoapIdsCall("emptyRecycleBin", soapEmptyRecycleBinCall{Ids: ids})
+}
+
+// doSoapIdsCall sends a soap call that takes a list of ids and returns a
+// result per id
+func (s *SalesforceUtils) doSoapIdsCall(action string, call interface{ idCount() int }) ([]RecordOperationResult, error) {
+	if call.idCount() == 0 {
+		return nil, errorx.IllegalArgument.New("input must not be empty")
+	}
+	if call.idCount() > soapMaxRecords {
+		return nil, errorx.IllegalArgument.New("input must not be larger than %d", soapMaxRecords)
+	}
+	response, err := doSoapRequest[struct {
+		Results []RecordOperationResult `xml:"result"`
+	}](s, action, call)
+	if err != nil {
+		return nil, err
+	}
+	return response.Results, validateSoapResultsLength(call.idCount(), len(response.Results))
+}
+
+// validateSoapResultsLength checks that a call returned a result per input
+func validateSoapResultsLength(inputs, results int) error {
+	if inputs != results {
+		return errorx.IllegalState.New("got %d results for %d inputs", results, inputs)
+	}
+	return nil
+}
+
+type soapUndeleteCall struct {
+	XMLName xml.Name `xml:"urn:undelete"`
+	Ids     []string `xml:"urn:ids"`
+}
+
+func (c soapUndeleteCall) idCount() int {
+	return len(c.Ids)
+}
+
+type soapEmptyRecycleBinCall struct {
+	XMLName xml.Name `xml:"urn:emptyRecycleBin"`
+	Ids     []string `xml:"urn:ids"`
+}
+
+func (c soapEmptyRecycleBinCall) idCount() int {
+	return len(c.Ids)
+}
+
+type soapMergeCall struct {
+	XMLName  xml.Name           `xml:"urn:merge"`
+	Requests []soapMergeRequest `xml:"urn:request"`
+}
+
+type soapMergeRequest struct {
+	MasterRecord     soapSObject `xml:"urn:masterRecord"`
+	RecordToMergeIds []string    `xml:"urn:recordToMergeIds"`
+}
+
+// soapSObject is a record in the partner api's generic sObject format
+type soapSObject struct {
+	TypeName string
+	Id       string
+	Fields   map[string]string
+}
+
+// MarshalXML writes the type and id in the sObject namespace, followed by
+// the fields in name order
+func (o soapSObject) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: "urn1:sObject"})
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	err = e.EncodeElement(o.TypeName, xml.StartElement{Name: xml.Name{Local: "urn1:type"}})
+	if err != nil {
+		return err
+	}
+	err = e.EncodeElement(o.Id, xml.StartElement{Name: xml.Name{Local: "urn1:Id"}})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(o.Fields))
+	for name := range o.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err = e.EncodeElement(o.Fields[name], xml.StartElement{Name: xml.Name{Local: name}})
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/joomcode/errorx"
 	"github.com/valyala/fasthttp"
@@ -56,6 +59,214 @@ func (s *SalesforceUtils) UpdateObject(typeName, id string, jsonBytes []byte) er
 	return nil
 }
 
+// ObjectVersion identifies a version of a record for optimistic concurrency,
+// zero values are not sent
+type ObjectVersion struct {
+	// ETag is sent as If-Match
+	ETag string
+	// LastModified is the record's LastModifiedDate, sent as
+	// If-Unmodified-Since
+	LastModified time.Time
+}
+
+// UpdateObjectIfUnmodified updates a record only if it still matches the
+// version the caller last read. a ConflictError is returned if the record
+// changed since, ConflictVersion gets its current version from the error
+// when salesforce sends it.
+func (s *SalesforceUtils) UpdateObjectIfUnmodified(typeName, id string, version ObjectVersion, jsonBytes []byte) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getObjectIdUrl(typeName, id))
+	req.Header.SetMethod(http.MethodPatch)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(jsonBytes)
+	return s.doConditionalObjectRequest(req, typeName, id, version)
+}
+
+// DeleteObjectIfUnmodified deletes a record only if it still matches the
+// version the caller last read. see UpdateObjectIfUnmodified for conflicts.
+func (s *SalesforceUtils) DeleteObjectIfUnmodified(typeName, id string, version ObjectVersion) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getObjectIdUrl(typeName, id))
+	req.Header.SetMethod(http.MethodDelete)
+	return s.doConditionalObjectRequest(req, typeName, id, version)
+}
+
+// doConditionalObjectRequest sends an update or delete with the conditional
+// headers of version, turning a 412 into a ConflictError
+func (s *SalesforceUtils) doConditionalObjectRequest(req *fasthttp.Request, typeName, id string, version ObjectVersion) error {
+	if version.ETag == "" && version.LastModified.IsZero() {
+		return errorx.IllegalArgument.New("version must have an ETag or a last modified time")
+	}
+	if version.ETag != "" {
+		req.Header.Set("If-Match", version.ETag)
+	}
+	if !version.LastModified.IsZero() {
+		req.Header.Set("If-Unmodified-Since", version.LastModified.UTC().Format(http.TimeFormat))
+	}
+	res, deferredFunc, err := s.sendRequestForResponse(req)
+	defer deferredFunc()
+	if err != nil {
+		return err
+	}
+	switch res.StatusCode() {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusPreconditionFailed:
+		conflict := ConflictError.New("%s %s was modified since the given version with body: %s", typeName, id, res.Body())
+		current := ObjectVersion{ETag: string(res.Header.Peek("ETag"))}
+		if lastModified := res.Header.Peek("Last-Modified"); len(lastModified) > 0 {
+			current.LastModified, _ = http.ParseTime(string(lastModified))
+		}
+		if current.ETag != "" || !current.LastModified.IsZero() {
+			conflict = conflict.WithProperty(conflictVersionProperty, current)
+		}
+		return conflict
+	case http.StatusNotFound:
+		return NotFoundError.New("%s %s not found with body: %s", typeName, id, res.Body())
+	}
+	return errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+}
+
+// UpsertObjectResponse is the response from UpsertObjectByExternalId
+type UpsertObjectResponse struct {
+	Id      string   `json:"id"`
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors"`
+	// Created is true when the record was created rather than updated
+	Created bool `json:"created"`
+}
+
+// UpsertObjectByExternalId creates or updates a single object, matching an
+// existing record where externalIdField equals externalId. the json body must
+// not include externalIdField itself.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/dome_upsert.htm
+func (s *SalesforceUtils) UpsertObjectByExternalId(typeName, externalIdField, externalId string, jsonBytes []byte) (response UpsertObjectResponse, err error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	uri := s.getExternalIdUrl(typeName, externalIdField, externalId)
+	req.SetRequestURI(uri)
+	req.Header.SetMethod(http.MethodPatch)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBody(jsonBytes)
+	body, statusCode, deferredFunc, requestErr := s.sendRequest(req)
+	defer deferredFunc()
+	if requestErr != nil {
+		err = requestErr
+		return
+	}
+	switch statusCode {
+	case http.StatusCreated, http.StatusOK:
+		err = json.Unmarshal(body, &response)
+		response.Created = statusCode == http.StatusCreated
+	case http.StatusNoContent:
+		// older api versions don't return a body when the record is updated
+		response.Success = true
+	default:
+		err = errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	return
+}
+
+// ObjectConditions are the conditional headers of a GetObjectConditional
+// request. zero values are not sent.
+type ObjectConditions struct {
+	// IfModifiedSince only returns the record if it changed after this time
+	IfModifiedSince time.Time
+	// IfNoneMatch only returns the record if its ETag doesn't match
+	IfNoneMatch string
+}
+
+// GetObjectResult describes the response to a GetObjectConditional request
+type GetObjectResult struct {
+	// NotModified is true when salesforce answered 304 Not Modified, in which
+	// case nothing was decoded
+	NotModified bool
+	ETag        string
+	// LastModified is zero when salesforce didn't send the header
+	LastModified time.Time
+}
+
+// Version gets the version of the record that was read, for use with
+// UpdateObjectIfUnmodified and DeleteObjectIfUnmodified
+func (r GetObjectResult) Version() ObjectVersion {
+	return ObjectVersion{ETag: r.ETag, LastModified: r.LastModified}
+}
+
+// GetObject gets a single record by id and decodes it into out, which can be
+// a pointer to a struct or a map. only the given fields are returned, or every
+// field when none are given. a NotFoundError is returned if the record doesn't
+// exist.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_sobject_retrieve_get.htm
+func (s *SalesforceUtils) GetObject(typeName, id string, out interface{}, fields ...string) error {
+	_, err := s.GetObjectConditional(typeName, id, ObjectConditions{}, out, fields...)
+	return err
+}
+
+// GetObjectConditional is the same as GetObject but sends conditional
+// headers. when the record hasn't changed the result has NotModified set and
+// out is left untouched.
+func (s *SalesforceUtils) GetObjectConditional(typeName, id string, conditions ObjectConditions, out interface{}, fields ...string) (GetObjectResult, error) {
+	return s.getObject(s.getObjectIdUrl(typeName, id), conditions, out, fields)
+}
+
+// GetObjectByExternalId gets a single record where externalIdField equals
+// externalId and decodes it into out. see GetObject for fields and errors.
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_sobject_upsert_get.htm
+func (s *SalesforceUtils) GetObjectByExternalId(typeName, externalIdField, externalId string, out interface{}, fields ...string) error {
+	_, err := s.GetObjectByExternalIdConditional(typeName, externalIdField, externalId, ObjectConditions{}, out, fields...)
+	return err
+}
+
+// GetObjectByExternalIdConditional is the same as GetObjectByExternalId but
+// sends conditional headers, see GetObjectConditional
+func (s *SalesforceUtils) GetObjectByExternalIdConditional(typeName, externalIdField, externalId string, conditions ObjectConditions, out interface{}, fields ...string) (GetObjectResult, error) {
+	return s.getObject(s.getExternalIdUrl(typeName, externalIdField, externalId), conditions, out, fields)
+}
+
+func (s *SalesforceUtils) getObject(uri string, conditions ObjectConditions, out interface{}, fields []string) (result GetObjectResult, err error) {
+	if len(fields) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, url.Values{"fields": {strings.Join(fields, ",")}}.Encode())
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(uri)
+	req.Header.SetMethod(http.MethodGet)
+	if !conditions.IfModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", conditions.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if conditions.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", conditions.IfNoneMatch)
+	}
+	res, deferredFunc, err := s.sendRequestForResponse(req)
+	defer deferredFunc()
+	if err != nil {
+		return result, err
+	}
+
+	result.ETag = string(res.Header.Peek("ETag"))
+	if lastModified := res.Header.Peek("Last-Modified"); len(lastModified) > 0 {
+		result.LastModified, _ = http.ParseTime(string(lastModified))
+	}
+	switch res.StatusCode() {
+	case http.StatusOK:
+		err = json.Unmarshal(res.Body(), out)
+	case http.StatusNotModified:
+		result.NotModified = true
+	case http.StatusNotFound:
+		err = NotFoundError.New("record not found at %s with body: %s", uri, res.Body())
+	case http.StatusMultipleChoices:
+		err = errorx.IllegalState.New("external id matches more than one record: %s", res.Body())
+	default:
+		err = errorx.IllegalState.New("unexpected status code: %d with body: %s", res.StatusCode(), res.Body())
+	}
+	return
+}
+
 func (s *SalesforceUtils) DeleteObject(typeName, id string) error {
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
@@ -75,7 +286,7 @@ func (s *SalesforceUtils) DeleteObject(typeName, id string) error {
 
 // DescribeObjectResponse is a simplified struct representation of the json
 // response from the "sObject Describe" API call. Currently only contains the
-// "name" and "fields" fields.
+// "name" and "fields" fields, see SObjectDescribe for the complete describe.
 type DescribeObjectResponse struct {
 	Name   string                         `json:"name"`
 	Fields []DescribeObjectResponseFields `json:"fields"`
@@ -92,23 +303,7 @@ type DescribeObjectResponseFields struct {
 
 // DescribeObject describes the object type, returning all of the field and types
 func (s *SalesforceUtils) DescribeObject(typeName string) (response DescribeObjectResponse, err error) {
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
-	uri := s.getDescribeUrl(typeName)
-	req.SetRequestURI(uri)
-	req.Header.SetMethod(http.MethodGet)
-	req.Header.Set("Content-Type", "application/json")
-	body, statusCode, deferredFunc, requestErr := s.sendRequest(req)
-	defer deferredFunc()
-	if requestErr != nil {
-		err = requestErr
-		return
-	}
-	if statusCode != http.StatusOK {
-		err = errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
-		return
-	}
-	err = json.Unmarshal(body, &response)
+	err = s.doDescribeRequest(typeName, &response)
 	return
 }
 
@@ -137,6 +332,18 @@ func (s *SalesforceUtils) getObjectIdUrl(typeName, id string) string {
 	return fmt.Sprintf("%s%s", s.Config.BaseUrl, s.getObjectIdPath(typeName, id))
 }
 
+// getExternalIdPath gets a formatted path to the endpoint for a specific
+// object by external id
+func (s *SalesforceUtils) getExternalIdPath(typeName, externalIdField, externalId string) string {
+	return fmt.Sprintf("%s/%s/%s", s.getTypePath(typeName), externalIdField, url.PathEscape(externalId))
+}
+
+// getExternalIdUrl gets a formatted full url to the endpoint for a specific
+// object by external id
+func (s *SalesforceUtils) getExternalIdUrl(typeName, externalIdField, externalId string) string {
+	return fmt.Sprintf("%s%s", s.Config.BaseUrl, s.getExternalIdPath(typeName, externalIdField, externalId))
+}
+
 // getDescribeUrl gets a formatted full url to the endoint for a specific object by id
 func (s *SalesforceUtils) getDescribeUrl(typeName string) string {
 	return fmt.Sprintf("%s/describe", s.getTypeUrl(typeName))
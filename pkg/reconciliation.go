@@ -0,0 +1,367 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joomcode/errorx"
+)
+
+// ReconciliationStatus is the outcome of a single input row
+type ReconciliationStatus string
+
+const (
+	ReconciliationStatusSucceeded ReconciliationStatus = "Succeeded"
+	ReconciliationStatusFailed    ReconciliationStatus = "Failed"
+	// ReconciliationStatusUnprocessed is used for rows that were never
+	// processed, either because a bulk job was aborted or because an
+	// allOrNone request was rolled back by another row's failure
+	ReconciliationStatusUnprocessed ReconciliationStatus = "Unprocessed"
+)
+
+// processingHaltedStatusCode is the status code salesforce gives to the rows
+// of an allOrNone request that were rolled back because another row failed
+const processingHaltedStatusCode = "PROCESSING_HALTED"
+
+// ReconciliationError is a single error reported by salesforce for a row
+type ReconciliationError struct {
+	StatusCode string   `json:"statusCode"`
+	Message    string   `json:"message"`
+	Fields     []string `json:"fields,omitempty"`
+}
+
+// ReconciliationRow is the outcome of a single input row, keyed by the
+// caller's correlation key
+type ReconciliationRow struct {
+	Key          string                `json:"key"`
+	Status       ReconciliationStatus  `json:"status"`
+	SalesforceId string                `json:"salesforceId,omitempty"`
+	Created      bool                  `json:"created,omitempty"`
+	Errors       []ReconciliationError `json:"errors,omitempty"`
+	// Record is the uploaded row for bulk results, used to re-drive failed
+	// rows
+	Record map[string]string `json:"record,omitempty"`
+}
+
+// ReconciliationReport merges the outcomes of a load into a single list of
+// rows
+type ReconciliationReport struct {
+	Rows []ReconciliationRow `json:"rows"`
+	// Columns are the uploaded columns, in order, for bulk results
+	Columns []string `json:"columns,omitempty"`
+}
+
+// ReconciliationSummary counts the rows of a report by status and error
+type ReconciliationSummary struct {
+	Total             int            `json:"total"`
+	Succeeded         int            `json:"succeeded"`
+	Failed            int            `json:"failed"`
+	Unprocessed       int            `json:"unprocessed"`
+	ErrorStatusCounts map[string]int `json:"errorStatusCounts"`
+}
+
+// ReconcileBulkIngestJob builds a report from the successful, failed and
+// unprocessed results of a bulk 2.0 ingest job. keyColumn is the uploaded
+// column used as the correlation key, e.g. an external id field.
+func (s *SalesforceUtils) ReconcileBulkIngestJob(jobID string, keyColumn string) (report ReconciliationReport, err error) {
+	results := []struct {
+		status ReconciliationStatus
+		get    func(string) ([]byte, error)
+	}{
+		{ReconciliationStatusSucceeded, s.GetBulkIngestJobSuccessfulResults},
+		{ReconciliationStatusFailed, s.GetBulkIngestJobFailedResults},
+		{ReconciliationStatusUnprocessed, s.GetBulkIngestJobUnprocessedRecords},
+	}
+	for _, result := range results {
+		body, getErr := result.get(jobID)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		header, rows, readErr := readBulkResultCsv(body)
+		if readErr != nil {
+			err = readErr
+			return
+		}
+		if header == nil {
+			continue
+		}
+		if report.Columns == nil {
+			for _, column := range header {
+				if !strings.HasPrefix(column, "sf__") {
+					report.Columns = append(report.Columns, column)
+				}
+			}
+		}
+		for _, values := range rows {
+			report.Rows = append(report.Rows, bulkResultToReconciliationRow(result.status, header, values, keyColumn))
+		}
+	}
+	report.sortByKey()
+	return
+}
+
+// bulkResultToReconciliationRow converts a single row of a bulk results csv
+func bulkResultToReconciliationRow(status ReconciliationStatus, header []string, values []string, keyColumn string) ReconciliationRow {
+	row := ReconciliationRow{Status: status, Record: map[string]string{}}
+	for i, column := range header {
+		if i >= len(values) {
+			break
+		}
+		switch column {
+		case "sf__Id":
+			row.SalesforceId = values[i]
+		case "sf__Created":
+			row.Created = values[i] == "true"
+		case "sf__Error":
+			row.Errors = append(row.Errors, parseBulkError(values[i]))
+		default:
+			row.Record[column] = values[i]
+		}
+	}
+	row.Key = row.Record[keyColumn]
+	return row
+}
+
+// parseBulkError parses an sf__Error value, which has the form
+// "STATUS_CODE:message:field1,field2 --" where the fields are optional
+func parseBulkError(value string) ReconciliationError {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) < 2 {
+		return ReconciliationError{Message: value}
+	}
+	reconciliationError := ReconciliationError{StatusCode: parts[0], Message: parts[1]}
+	if strings.HasSuffix(reconciliationError.Message, "--") {
+		message := strings.TrimSpace(strings.TrimSuffix(reconciliationError.Message, "--"))
+		lastColon := strings.LastIndex(message, ":")
+		if lastColon >= 0 {
+			fields := strings.TrimSpace(message[lastColon+1:])
+			message = message[:lastColon]
+			if fields != "" {
+				reconciliationError.Fields = strings.Split(fields, ",")
+			}
+		}
+		reconciliationError.Message = message
+	}
+	return reconciliationError
+}
+
+// ReconcileCollectionsResults builds a report from the results of a
+// collections request. keys are the correlation keys of the request's records
+// in the same order, since the collections api returns one result per record
+// in request order.
+func ReconcileCollectionsResults(keys []string, items []CollectionsResponseItem) (ReconciliationReport, error) {
+	if len(keys) != len(items) {
+		return ReconciliationReport{}, errorx.IllegalArgument.New("got %d keys for %d results", len(keys), len(items))
+	}
+	report := ReconciliationReport{}
+	for i, item := range items {
+		row := ReconciliationRow{Key: keys[i], SalesforceId: item.Id, Status: ReconciliationStatusSucceeded}
+		for _, itemError := range item.Errors {
+			row.Errors = append(row.Errors, ReconciliationError{
+				StatusCode: itemError.StatusCode,
+				Message:    itemError.Message,
+				Fields:     itemError.Fields,
+			})
+		}
+		if !item.Success || len(row.Errors) > 0 {
+			row.Status = failedOrHalted(row.Errors)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// ReconcileCompositeResults builds a report from the results of a composite
+// request. keysByReferenceId maps each subrequest's reference id to its
+// correlation key, subrequests missing from the map are keyed by their
+// reference id.
+func ReconcileCompositeResults(keysByReferenceId map[string]string, response CompositeResponse) ReconciliationReport {
+	report := ReconciliationReport{}
+	for _, subResponse := range response.CompositeResponse {
+		key, ok := keysByReferenceId[subResponse.ReferenceId]
+		if !ok {
+			key = subResponse.ReferenceId
+		}
+		row := ReconciliationRow{Key: key, Status: ReconciliationStatusSucceeded}
+		if result, err := subResponse.Result(); err == nil {
+			row.SalesforceId = result.Id
+		}
+		compositeErrors, err := subResponse.Errors()
+		if err != nil {
+			row.Errors = append(row.Errors, ReconciliationError{Message: string(subResponse.Body)})
+		}
+		for _, compositeError := range compositeErrors {
+			row.Errors = append(row.Errors, ReconciliationError{
+				StatusCode: compositeError.ErrorCode,
+				Message:    compositeError.Message,
+				Fields:     compositeError.Fields,
+			})
+		}
+		if !subResponse.Succeeded() || len(row.Errors) > 0 {
+			row.Status = failedOrHalted(row.Errors)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report
+}
+
+// failedOrHalted gets the status of a row that didn't succeed. rows whose
+// only error is PROCESSING_HALTED were rolled back rather than failing.
+func failedOrHalted(errors []ReconciliationError) ReconciliationStatus {
+	if len(errors) == 0 {
+		return ReconciliationStatusFailed
+	}
+	for _, rowError := range errors {
+		if rowError.StatusCode != processingHaltedStatusCode {
+			return ReconciliationStatusFailed
+		}
+	}
+	return ReconciliationStatusUnprocessed
+}
+
+func (r *ReconciliationReport) sortByKey() {
+	sort.SliceStable(r.Rows, func(i, j int) bool {
+		return r.Rows[i].Key < r.Rows[j].Key
+	})
+}
+
+// Merge appends the rows of other reports to this one, e.g. to combine the
+// reports of several jobs from the same load
+func (r *ReconciliationReport) Merge(others ...ReconciliationReport) {
+	for _, other := range others {
+		r.Rows = append(r.Rows, other.Rows...)
+		if r.Columns == nil {
+			r.Columns = other.Columns
+		}
+	}
+}
+
+// RowsWithStatus gets the rows with the given status
+func (r ReconciliationReport) RowsWithStatus(status ReconciliationStatus) []ReconciliationRow {
+	var rows []ReconciliationRow
+	for _, row := range r.Rows {
+		if row.Status == status {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// RedriveRows gets the rows that should be sent again, which are the failed
+// and unprocessed rows
+func (r ReconciliationReport) RedriveRows() []ReconciliationRow {
+	var rows []ReconciliationRow
+	for _, row := range r.Rows {
+		if row.Status != ReconciliationStatusSucceeded {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// ErrorsByStatusCode groups the failed rows by the status codes of their
+// errors. a row with several errors appears under each of its status codes.
+func (r ReconciliationReport) ErrorsByStatusCode() map[string][]ReconciliationRow {
+	grouped := map[string][]ReconciliationRow{}
+	for _, row := range r.RowsWithStatus(ReconciliationStatusFailed) {
+		seen := map[string]bool{}
+		for _, rowError := range row.Errors {
+			if seen[rowError.StatusCode] {
+				continue
+			}
+			seen[rowError.StatusCode] = true
+			grouped[rowError.StatusCode] = append(grouped[rowError.StatusCode], row)
+		}
+	}
+	return grouped
+}
+
+// Summary counts the rows of the report by status and by error status code
+func (r ReconciliationReport) Summary() ReconciliationSummary {
+	summary := ReconciliationSummary{Total: len(r.Rows), ErrorStatusCounts: map[string]int{}}
+	for _, row := range r.Rows {
+		switch row.Status {
+		case ReconciliationStatusSucceeded:
+			summary.Succeeded++
+		case ReconciliationStatusFailed:
+			summary.Failed++
+		case ReconciliationStatusUnprocessed:
+			summary.Unprocessed++
+		}
+	}
+	for statusCode, rows := range r.ErrorsByStatusCode() {
+		summary.ErrorStatusCounts[statusCode] = len(rows)
+	}
+	return summary
+}
+
+// WriteJSON writes the summary and every row of the report as json
+func (r ReconciliationReport) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Summary ReconciliationSummary `json:"summary"`
+		ReconciliationReport
+	}{r.Summary(), r})
+}
+
+// WriteCSV writes one csv row per report row. multiple errors on a row are
+// joined with "; ".
+func (r ReconciliationReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"key", "status", "salesforceId", "created", "statusCodes", "messages", "fields"})
+	if err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		var statusCodes, messages, fields []string
+		for _, rowError := range row.Errors {
+			statusCodes = append(statusCodes, rowError.StatusCode)
+			messages = append(messages, rowError.Message)
+			fields = append(fields, strings.Join(rowError.Fields, ","))
+		}
+		err = writer.Write([]string{
+			row.Key,
+			string(row.Status),
+			row.SalesforceId,
+			strconv.FormatBool(row.Created),
+			strings.Join(statusCodes, "; "),
+			strings.Join(messages, "; "),
+			strings.Join(fields, "; "),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteRedriveCSV writes the uploaded columns of the failed and unprocessed
+// rows as csv, ready to be loaded again. only reports built from bulk results
+// have the uploaded rows.
+func (r ReconciliationReport) WriteRedriveCSV(w io.Writer) error {
+	if len(r.Columns) == 0 {
+		return errorx.IllegalState.New("report has no uploaded rows to re-drive")
+	}
+	writer := csv.NewWriter(w)
+	err := writer.Write(r.Columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range r.RedriveRows() {
+		values := make([]string, len(r.Columns))
+		for i, column := range r.Columns {
+			values[i] = row.Record[column]
+		}
+		err = writer.Write(values)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/catalystcommunity/salesforce-utils/pkg/types"
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// replicationMaxLookback is how far back salesforce keeps the updated and
+	// deleted records of an object
+	replicationMaxLookback = 30 * 24 * time.Hour
+	// replicationMinWindow is the smallest window that is split when
+	// salesforce returns too many ids, since it ignores seconds
+	replicationMinWindow = time.Minute
+	// exceededIdLimitErrorCode is returned when a window has more than
+	// 600,000 ids
+	exceededIdLimitErrorCode = "EXCEEDED_ID_LIMIT"
+	// replicationDateLayout is the layout of the start and end parameters
+	replicationDateLayout = "2006-01-02T15:04:05+00:00"
+)
+
+// UpdatedRecordsResult is the response from the "Get Updated" API call
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_getupdated.htm
+type UpdatedRecordsResult struct {
+	// Start is the start of the range that was read. it's later than the
+	// requested start when that was beyond the 30 day lookback.
+	Start time.Time `json:"-"`
+	Ids   []string  `json:"ids"`
+	// LatestDateCovered is the time up to which the ids are complete, use it
+	// as the start of the next poll
+	LatestDateCovered types.DateTime `json:"latestDateCovered"`
+}
+
+// DeletedRecordsResult is the response from the "Get Deleted" API call
+//
+// ref: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_getdeleted.htm
+type DeletedRecordsResult struct {
+	// Start is the start of the range that was read. it's later than the
+	// requested start when that was beyond the 30 day lookback.
+	Start          time.Time       `json:"-"`
+	DeletedRecords []DeletedRecord `json:"deletedRecords"`
+	// EarliestDateAvailable is the oldest time deletions are kept for
+	EarliestDateAvailable types.DateTime `json:"earliestDateAvailable"`
+	// LatestDateCovered is the time up to which the deletions are complete,
+	// use it as the start of the next poll
+	LatestDateCovered types.DateTime `json:"latestDateCovered"`
+}
+
+// DeletedRecord is a single deleted record
+type DeletedRecord struct {
+	Id          string         `json:"id"`
+	DeletedDate types.DateTime `json:"deletedDate"`
+}
+
+// GetUpdated gets the ids of the records of typeName that were created or
+// updated between start and end. salesforce only keeps the last 30 days, so
+// an earlier start is moved up to the lookback limit and the range that was
+// read starts at result.Start. a window that holds more ids than salesforce
+// returns at once is split and the results merged.
+func (s *SalesforceUtils) GetUpdated(typeName string, start, end time.Time) (result UpdatedRecordsResult, err error) {
+	seen := map[string]bool{}
+	result.Start, err = s.forEachReplicationWindow(start, end, func(windowStart, windowEnd time.Time) error {
+		var window UpdatedRecordsResult
+		err := s.doReplicationRequest(s.getReplicationUrl(typeName, "updated", windowStart, windowEnd), &window)
+		if err != nil {
+			return err
+		}
+		for _, id := range window.Ids {
+			if !seen[id] {
+				seen[id] = true
+				result.Ids = append(result.Ids, id)
+			}
+		}
+		result.LatestDateCovered = window.LatestDateCovered
+		return nil
+	})
+	return
+}
+
+// GetDeleted gets the records of typeName that were deleted between start
+// and end. see GetUpdated for how the window is limited and split.
+func (s *SalesforceUtils) GetDeleted(typeName string, start, end time.Time) (result DeletedRecordsResult, err error) {
+	seen := map[string]bool{}
+	result.Start, err = s.forEachReplicationWindow(start, end, func(windowStart, windowEnd time.Time) error {
+		var window DeletedRecordsResult
+		err := s.doReplicationRequest(s.getReplicationUrl(typeName, "deleted", windowStart, windowEnd), &window)
+		if err != nil {
+			return err
+		}
+		for _, record := range window.DeletedRecords {
+			if !seen[record.Id] {
+				seen[record.Id] = true
+				result.DeletedRecords = append(result.DeletedRecords, record)
+			}
+		}
+		if result.EarliestDateAvailable.IsZero() {
+			result.EarliestDateAvailable = window.EarliestDateAvailable
+		}
+		result.LatestDateCovered = window.LatestDateCovered
+		return nil
+	})
+	return
+}
+
+// forEachReplicationWindow clamps start to the lookback limit and calls fn
+// for the window between it and end, returning the clamped start. when
+// salesforce returns too many ids for a window it is halved and both halves
+// are requested instead, in order.
+func (s *SalesforceUtils) forEachReplicationWindow(start, end time.Time, fn func(windowStart, windowEnd time.Time) error) (time.Time, error) {
+	if !end.After(start) {
+		return start, errorx.IllegalArgument.New("end %s must be after start %s", end, start)
+	}
+	// rounded up to the next minute, since salesforce ignores seconds and
+	// the limit moves on while the request is sent
+	earliest := time.Now().Add(-replicationMaxLookback).Add(replicationMinWindow).Truncate(time.Minute)
+	if start.Before(earliest) {
+		start = earliest
+	}
+	if !end.After(start) {
+		return start, errorx.IllegalArgument.New("end %s is more than 30 days ago, salesforce doesn't keep changes that old", end)
+	}
+
+	var request func(windowStart, windowEnd time.Time) error
+	request = func(windowStart, windowEnd time.Time) error {
+		err := fn(windowStart, windowEnd)
+		if !errorx.IsOfType(err, exceededIdLimitError) {
+			return err
+		}
+		if windowEnd.Sub(windowStart) <= replicationMinWindow {
+			return errorx.Decorate(err, "window %s to %s can't be split further", windowStart, windowEnd)
+		}
+		middle := windowStart.Add(windowEnd.Sub(windowStart) / 2).Truncate(time.Minute)
+		if !middle.After(windowStart) {
+			middle = windowStart.Add(replicationMinWindow)
+		}
+		err = request(windowStart, middle)
+		if err != nil {
+			return err
+		}
+		return request(middle, windowEnd)
+	}
+	return start, request(start, end)
+}
+
+// exceededIdLimitError is returned by doReplicationRequest when a window
+// holds too many ids, so the window can be split
+var exceededIdLimitError = SalesforceErrors.NewType("exceeded_id_limit")
+
+func (s *SalesforceUtils) doReplicationRequest(uri string, out interface{}) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(uri)
+	req.Header.SetMethod(http.MethodGet)
+	body, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		var compositeErrors []CompositeError
+		if json.Unmarshal(body, &compositeErrors) == nil {
+			for _, compositeError := range compositeErrors {
+				if compositeError.ErrorCode == exceededIdLimitErrorCode {
+					return exceededIdLimitError.New("%s", compositeError.Message)
+				}
+			}
+		}
+		return errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// getReplicationUrl gets a formatted full url to the updated or deleted
+// endpoint of an object type
+func (s *SalesforceUtils) getReplicationUrl(typeName, resource string, start, end time.Time) string {
+	query := url.Values{
+		"start": {start.UTC().Format(replicationDateLayout)},
+		"end":   {end.UTC().Format(replicationDateLayout)},
+	}
+	return fmt.Sprintf("%s/%s/?%s", s.getTypeUrl(typeName), resource, query.Encode())
+}
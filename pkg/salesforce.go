@@ -13,6 +13,13 @@ type SalesforceUtils struct {
 	Config         Config
 	Credentials    SalesforceCredentials
 	FastHTTPClient *fasthttp.Client
+	// StreamingHTTPClient is used for downloads that are streamed rather
+	// than read into memory. NewSalesforceUtils derives it from
+	// FastHTTPClient with newStreamingClient.
+	StreamingHTTPClient *fasthttp.Client
+	// DescribeCache is used by DescribeObjectCached, set to nil to disable
+	// caching
+	DescribeCache *DescribeCache
 }
 
 type Config struct {
@@ -56,7 +63,7 @@ func NewSalesforceUtils(authenticate bool, config Config) (*SalesforceUtils, err
 	if err != nil {
 		return nil, err
 	}
-	utils := &SalesforceUtils{Config: config}
+	utils := &SalesforceUtils{Config: config, DescribeCache: NewDescribeCache(0)}
 	utils.Config = config
 	// allow passing a custom fasthttp client, default to empty
 	if config.FastHTTPClient != nil {
@@ -64,6 +71,7 @@ func NewSalesforceUtils(authenticate bool, config Config) (*SalesforceUtils, err
 	} else {
 		utils.FastHTTPClient = &fasthttp.Client{}
 	}
+	utils.StreamingHTTPClient = newStreamingClient(utils.FastHTTPClient)
 	// authenticate
 	if authenticate {
 		err = utils.Authenticate()
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+	"github.com/valyala/fasthttp"
+)
+
+// namespaces of the partner soap api
+const (
+	soapEnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soapPartnerNamespace  = "urn:partner.soap.sforce.com"
+	soapSObjectNamespace  = "urn:sobject.partner.soap.sforce.com"
+	soapXsiNamespace      = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// soapRequestEnvelope is the envelope of a partner soap api call. the
+// prefixes are written literally, since encoding/xml can't declare them.
+type soapRequestEnvelope struct {
+	XMLName           xml.Name `xml:"soapenv:Envelope"`
+	EnvelopeNamespace string   `xml:"xmlns:soapenv,attr"`
+	PartnerNamespace  string   `xml:"xmlns:urn,attr"`
+	SObjectNamespace  string   `xml:"xmlns:urn1,attr"`
+	XsiNamespace      string   `xml:"xmlns:xsi,attr"`
+	SessionId         string   `xml:"soapenv:Header>urn:SessionHeader>urn:sessionId"`
+	Body              soapRequestBody
+}
+
+// soapRequestBody wraps the call element, which names itself with an
+// XMLName field
+type soapRequestBody struct {
+	XMLName xml.Name `xml:"soapenv:Body"`
+	Call    interface{}
+}
+
+// soapResponseEnvelope is the envelope of a partner soap api response, T is
+// the call's response element
+type soapResponseEnvelope[T any] struct {
+	Body struct {
+		Fault    *soapFault `xml:"Fault"`
+		Response T          `xml:",any"`
+	} `xml:"Body"`
+}
+
+// soapFault is the error returned by the soap api when a whole call fails
+type soapFault struct {
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+}
+
+// doSoapRequest sends a partner soap api call and unmarshals the response
+// element of the body into response
+func doSoapRequest[T any](s *SalesforceUtils, action string, body interface{}) (response T, err error) {
+	reqBodyBytes, err := xml.Marshal(soapRequestEnvelope{
+		EnvelopeNamespace: soapEnvelopeNamespace,
+		PartnerNamespace:  soapPartnerNamespace,
+		SObjectNamespace:  soapSObjectNamespace,
+		XsiNamespace:      soapXsiNamespace,
+		SessionId:         s.Credentials.AccessToken,
+		Body:              soapRequestBody{Call: body},
+	})
+	if err != nil {
+		return response, err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI(s.getSoapUrl())
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.Set("Content-Type", "text/xml; charset=UTF-8")
+	req.Header.Set("SOAPAction", action)
+	req.SetBody(append([]byte(xml.Header), reqBodyBytes...))
+
+	resBody, statusCode, deferredFunc, err := s.sendRequest(req)
+	defer deferredFunc()
+	if err != nil {
+		return response, err
+	}
+	var envelope soapResponseEnvelope[T]
+	unmarshalErr := xml.Unmarshal(resBody, &envelope)
+	if unmarshalErr == nil && envelope.Body.Fault != nil {
+		return response, errorx.IllegalState.New("%s failed with %s: %s", action, envelope.Body.Fault.FaultCode, envelope.Body.Fault.FaultString)
+	}
+	if statusCode != http.StatusOK {
+		return response, errorx.IllegalState.New("unexpected status code: %d with body: %s", statusCode, resBody)
+	}
+	if unmarshalErr != nil {
+		return response, errorx.IllegalState.New("failed to unmarshal response: %s", unmarshalErr)
+	}
+	return envelope.Body.Response, nil
+}
+
+// getSoapUrl gets a formatted full url to the partner soap api
+func (s *SalesforceUtils) getSoapUrl() string {
+	return fmt.Sprintf("%s/services/Soap/u/%s", s.Config.BaseUrl, s.Config.ApiVersion)
+}
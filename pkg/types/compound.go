@@ -0,0 +1,24 @@
+package types
+
+// Address is a salesforce compound address field, e.g. BillingAddress. the
+// compound field is read only, set the individual fields such as
+// BillingStreet to change it.
+type Address struct {
+	Street          string   `json:"street,omitempty"`
+	City            string   `json:"city,omitempty"`
+	State           string   `json:"state,omitempty"`
+	StateCode       string   `json:"stateCode,omitempty"`
+	PostalCode      string   `json:"postalCode,omitempty"`
+	Country         string   `json:"country,omitempty"`
+	CountryCode     string   `json:"countryCode,omitempty"`
+	Latitude        *float64 `json:"latitude,omitempty"`
+	Longitude       *float64 `json:"longitude,omitempty"`
+	GeocodeAccuracy string   `json:"geocodeAccuracy,omitempty"`
+}
+
+// Location is a salesforce compound geolocation field. like Address it is
+// read only, set the __Latitude__s and __Longitude__s fields to change it.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
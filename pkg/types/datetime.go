@@ -0,0 +1,194 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// layouts salesforce uses for date, datetime and time values
+const (
+	DateLayout     = "2006-01-02"
+	DateTimeLayout = "2006-01-02T15:04:05.000-0700"
+	TimeLayout     = "15:04:05.000Z"
+	// bulkDateTimeLayout is the datetime layout of bulk api csv values
+	bulkDateTimeLayout = "2006-01-02T15:04:05.000Z"
+)
+
+// dateTimeLayouts are the layouts accepted when parsing a datetime, since
+// salesforce returns DateTimeLayout but other tools often write RFC 3339
+var dateTimeLayouts = []string{DateTimeLayout, time.RFC3339Nano, "2006-01-02T15:04:05-0700"}
+
+// timeLayouts are the layouts accepted when parsing a time
+var timeLayouts = []string{TimeLayout, "15:04:05Z", "15:04:05.000", "15:04:05"}
+
+// Date is a salesforce date field, formatted as 2006-01-02. the zero value
+// is written as null.
+type Date struct {
+	time.Time
+}
+
+// NewDate creates a Date for the day of t, in t's location
+func NewDate(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses a date formatted as 2006-01-02
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, errorx.IllegalFormat.Wrap(err, "invalid date %q", value)
+	}
+	return Date{t}, nil
+}
+
+// String formats the date as 2006-01-02, or empty for the zero value
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return marshalTimeString(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeString(data, func(value string) (err error) {
+		*d, err = ParseDate(value)
+		return
+	})
+}
+
+// MarshalBulkCSV formats the date for the bulk api
+func (d Date) MarshalBulkCSV() (string, error) {
+	return d.String(), nil
+}
+
+// DateTime is a salesforce datetime field, formatted as
+// 2006-01-02T15:04:05.000+0000. the zero value is written as null.
+type DateTime struct {
+	time.Time
+}
+
+// NewDateTime creates a DateTime from t
+func NewDateTime(t time.Time) DateTime {
+	return DateTime{t}
+}
+
+// ParseDateTime parses a datetime in the salesforce format or RFC 3339
+func ParseDateTime(value string) (DateTime, error) {
+	var err error
+	for _, layout := range dateTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return DateTime{t}, nil
+		}
+	}
+	return DateTime{}, errorx.IllegalFormat.Wrap(err, "invalid datetime %q", value)
+}
+
+// String formats the datetime in UTC in the salesforce format, or empty for
+// the zero value
+func (d DateTime) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.UTC().Format(DateTimeLayout)
+}
+
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	return marshalTimeString(d.String())
+}
+
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeString(data, func(value string) (err error) {
+		*d, err = ParseDateTime(value)
+		return
+	})
+}
+
+// MarshalBulkCSV formats the datetime for the bulk api
+func (d DateTime) MarshalBulkCSV() (string, error) {
+	if d.IsZero() {
+		return "", nil
+	}
+	return d.UTC().Format(bulkDateTimeLayout), nil
+}
+
+// Time is a salesforce time field, formatted as 15:04:05.000Z. only the
+// clock of the wrapped time is used. the zero value is midnight, not null,
+// use Nullable[Time] for a time that can be null.
+type Time struct {
+	time.Time
+}
+
+// NewTime creates a Time for the clock of t in UTC
+func NewTime(t time.Time) Time {
+	t = t.UTC()
+	return Time{time.Date(0, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)}
+}
+
+// ParseTime parses a time formatted as 15:04:05.000Z
+func ParseTime(value string) (Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return Time{t}, nil
+		}
+	}
+	return Time{}, errorx.IllegalFormat.Wrap(err, "invalid time %q", value)
+}
+
+// String formats the time as 15:04:05.000Z
+func (t Time) String() string {
+	return t.UTC().Format(TimeLayout)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeString(data, func(value string) (err error) {
+		*t, err = ParseTime(value)
+		return
+	})
+}
+
+// MarshalBulkCSV formats the time for the bulk api
+func (t Time) MarshalBulkCSV() (string, error) {
+	return t.String(), nil
+}
+
+// marshalTimeString writes a formatted value as a json string, or null when
+// it's empty
+func marshalTimeString(value string) ([]byte, error) {
+	if value == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(value)
+}
+
+// unmarshalTimeString reads a json string and parses it, leaving the value
+// untouched for null or an empty string
+func unmarshalTimeString(data []byte, parse func(string) error) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+	return parse(value)
+}
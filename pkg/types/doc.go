@@ -0,0 +1,9 @@
+// Package types has value types that encode the way salesforce expects, both
+// as json for the rest api and as csv values for the bulk api.
+//
+// salesforce uses its own formats for dates and datetimes, joins
+// multipicklist values with semicolons and needs an explicit null to clear a
+// field on update. Date, DateTime, Time and MultiPicklist handle the formats,
+// and Nullable tells an unset field, which is left out of the body, apart
+// from an explicit null.
+package types
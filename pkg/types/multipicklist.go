@@ -0,0 +1,61 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MultiPicklist is a salesforce multipicklist field. salesforce sends and
+// expects the selected values joined with semicolons.
+type MultiPicklist []string
+
+// ParseMultiPicklist splits a semicolon joined multipicklist value
+func ParseMultiPicklist(value string) MultiPicklist {
+	if value == "" {
+		return MultiPicklist{}
+	}
+	return strings.Split(value, ";")
+}
+
+// String joins the values with semicolons
+func (m MultiPicklist) String() string {
+	return strings.Join(m, ";")
+}
+
+// Contains reports whether value is selected
+func (m MultiPicklist) Contains(value string) bool {
+	for _, selected := range m {
+		if selected == value {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON writes the values as a single semicolon joined string, or null
+// when m is nil
+func (m MultiPicklist) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(m.String())
+}
+
+func (m *MultiPicklist) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*m = nil
+		return nil
+	}
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	*m = ParseMultiPicklist(value)
+	return nil
+}
+
+// MarshalBulkCSV formats the values for the bulk api
+func (m MultiPicklist) MarshalBulkCSV() (string, error) {
+	return m.String(), nil
+}
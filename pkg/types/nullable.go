@@ -0,0 +1,113 @@
+package types
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// bulkNull is the bulk api csv value that sets a field to null
+const bulkNull = "#N/A"
+
+// Nullable is a field that is either unset, set to a value or set to an
+// explicit null. unset fields are left out of json bodies when the field is
+// tagged omitempty and written as empty bulk csv values, which leaves the
+// field unchanged. null is written as json null and as #N/A in bulk csv,
+// which clears the field.
+//
+// it's a map so that omitempty can tell unset apart from null: the zero
+// value is an empty map, a value is stored under true and null under false.
+// use the constructors and methods rather than the map itself.
+type Nullable[T any] map[bool]T
+
+// NewNullable creates a Nullable set to value
+func NewNullable[T any](value T) Nullable[T] {
+	return Nullable[T]{true: value}
+}
+
+// NewNull creates a Nullable set to an explicit null
+func NewNull[T any]() Nullable[T] {
+	var zero T
+	return Nullable[T]{false: zero}
+}
+
+// Get gets the value, ok is false when the field is unset or null
+func (n Nullable[T]) Get() (value T, ok bool) {
+	value, ok = n[true]
+	return
+}
+
+// IsNull reports whether the field is set to an explicit null
+func (n Nullable[T]) IsNull() bool {
+	_, ok := n[false]
+	return ok
+}
+
+// IsSpecified reports whether the field is set to a value or to null
+func (n Nullable[T]) IsSpecified() bool {
+	return len(n) != 0
+}
+
+// Set sets the field to value
+func (n *Nullable[T]) Set(value T) {
+	*n = NewNullable(value)
+}
+
+// SetNull sets the field to an explicit null
+func (n *Nullable[T]) SetNull() {
+	*n = NewNull[T]()
+}
+
+// Unset resets the field to unset
+func (n *Nullable[T]) Unset() {
+	*n = nil
+}
+
+// MarshalJSON writes the value, or null when the field is null. an unset
+// field is also written as null, tag the field omitempty to leave it out.
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	value, ok := n.Get()
+	if !ok {
+		return []byte("null"), nil
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON sets the field to null for json null, and to the value
+// otherwise
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		n.SetNull()
+		return nil
+	}
+	var value T
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	n.Set(value)
+	return nil
+}
+
+// MarshalBulkCSV writes the value for the bulk api, #N/A when the field is
+// null and empty when it's unset
+func (n Nullable[T]) MarshalBulkCSV() (string, error) {
+	if n.IsNull() {
+		return bulkNull, nil
+	}
+	value, ok := n.Get()
+	if !ok {
+		return "", nil
+	}
+	switch v := interface{}(value).(type) {
+	case interface{ MarshalBulkCSV() (string, error) }:
+		return v.MarshalBulkCSV()
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		return string(text), err
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return fmt.Sprint(value), nil
+}